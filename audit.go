@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/audit"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/safety"
+)
+
+// Deletes caches and records each deletion, and each cache refused by the
+// guard, in the audit log.
+type auditor struct {
+	manifest *apps.Manifest
+	guard    *safety.Guard
+	// Why the clean runs, e.g. clean, schedule or watch
+	reason string
+	// User whose caches are cleaned, empty for the current user
+	owner string
+}
+
+func auditLogPath() string {
+	if config.Runtime.AuditLog != "" {
+		return config.Runtime.AuditLog
+	}
+	return config.GetAuditLogPath()
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func (a *auditor) newRecord(app *apps.App, c cacheResult) audit.Record {
+	host, _ := os.Hostname()
+	return audit.Record{
+		Time:            time.Now(),
+		User:            currentUser(),
+		Host:            host,
+		Owner:           a.owner,
+		Reason:          a.reason,
+		App:             app.Name,
+		Path:            c.path,
+		Pattern:         string(c.cache.Path),
+		ManifestVersion: a.manifest.Version,
+		ManifestUpdated: a.manifest.LastUpdated,
+		Bytes:           c.size,
+		Method:          audit.MethodDelete,
+		Result:          audit.ResultDeleted,
+	}
+}
+
+func (a *auditor) write(l *log.Logger, record audit.Record) {
+	if err := audit.Append(auditLogPath(), record); err != nil {
+		l.Warn("Could not write to the audit log %s: %s", auditLogPath(), err)
+	}
+}
+
+// Checks that the guard allows deleting the cache, recording it as refused
+// otherwise.
+func (a *auditor) check(l *log.Logger, app *apps.App, c cacheResult) error {
+	err := a.guard.Check(c.path)
+	if err != nil {
+		record := a.newRecord(app, c)
+		record.Result = audit.ResultRefused
+		record.Bytes = 0
+		record.Error = err.Error()
+		a.write(l, record)
+	}
+	return err
+}
+
+func (a *auditor) remove(l *log.Logger, app *apps.App, c cacheResult) error {
+	err := a.guard.RemoveAll(c.path, c.info)
+	record := a.newRecord(app, c)
+	var refused *safety.RefusedError
+	if errors.As(err, &refused) {
+		record.Result = audit.ResultRefused
+	} else if err != nil {
+		record.Result = audit.ResultFailed
+	}
+	if err != nil {
+		record.Bytes = 0
+		record.Error = err.Error()
+	}
+	a.write(l, record)
+	return err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/ansi"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/audit"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+)
+
+// Lists the deletions of the audit log.
+func runAudit(l *log.Logger, args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	since := flags.String("since", "", "only deletions since a date, a time or a duration such as 7d")
+	until := flags.String("until", "", "only deletions before a time, or up to a date included")
+	app := flags.String("app", "", "only deletions of the caches of this app")
+	flags.String("format", "", "output format, text or json")
+	flags.Parse(args)
+	if err := applyFlags(flags, map[string]string{"format": "output"}); err != nil {
+		return err
+	}
+
+	filter := audit.Filter{App: *app}
+	var err error
+	if *since != "" {
+		if filter.Since, err = parseAuditTime(*since, false); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if filter.Until, err = parseAuditTime(*until, true); err != nil {
+			return err
+		}
+	}
+	records, err := audit.Query(auditLogPath(), filter)
+	if err != nil {
+		return fmt.Errorf("error reading the audit log (%s)", err)
+	}
+
+	if config.Runtime.Output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, r := range records {
+			if err := encoder.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(records) == 0 {
+		l.Info("No deletion recorded in %s", auditLogPath())
+		return nil
+	}
+	var freed int64
+	for _, r := range records {
+		result := ansi.Str(fmt.Sprintf("%-8s", r.Result)).Style(ansi.Green)
+		if r.Result != audit.ResultDeleted {
+			result = ansi.Str(fmt.Sprintf("%-8s", r.Result)).Style(ansi.Red)
+		}
+		by := r.User + "@" + r.Host
+		if r.Owner != "" {
+			by += " for " + r.Owner
+		}
+		fmt.Printf("%s  %s %10s  %-12s %s  %s\n", r.Time.Local().Format("2006-01-02 15:04"), result, io.HumanizeBytes(r.Bytes), r.App, r.Path, ansi.Str("("+r.Reason+", "+by+")").Style(ansi.Dim))
+		if r.Error != "" {
+			fmt.Printf("  %s\n", ansi.Str(r.Error).Style(ansi.Red))
+		}
+		freed += r.Bytes
+	}
+	fmt.Printf("\n%d deletions, %s freed\n", len(records), io.HumanizeBytes(freed))
+	return nil
+}
+
+// Parses a date, an RFC 3339 time, or a duration before now such as 12h or
+// 7d. A date as the end of a range includes the whole day.
+func parseAuditTime(s string, end bool) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		if end {
+			return date.AddDate(0, 0, 1), nil
+		}
+		return date, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s, expected a date, an RFC 3339 time or a duration", s)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/ansi"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/disk"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/safety"
+)
+
+func runClean(l *log.Logger, args []string) error {
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	yes := flags.Bool("yes", false, "do not ask for confirmation")
+	flags.Bool("include-user-data", false, "also delete caches that contain user data")
+	flags.Bool("include-unknown-risk", false, "also delete caches with an unknown risk without asking")
+	on := flags.String("on", "", "only clean the caches on the filesystem containing this path, e.g. /home")
+	scheduled := flags.Bool("scheduled", false, "run as a scheduled clean, implies -yes and does nothing on battery or under high load")
+	userFlags := addUserFlags(flags)
+	flags.Parse(args)
+	if err := applyFlags(flags, map[string]string{
+		"include-user-data":    "policy.include_user_data",
+		"include-unknown-risk": "policy.include_unknown_risk",
+	}); err != nil {
+		return err
+	}
+	opts := cleanOptions{
+		yes:                *yes,
+		includeUserData:    config.Runtime.IncludeUserData,
+		includeUnknownRisk: config.Runtime.IncludeUnknownRisk,
+		reason:             "clean",
+	}
+	if *scheduled {
+		l.Info("Scheduled clean at %s", time.Now().Format(time.RFC3339))
+		if reason := skipScheduledClean(l); reason != "" {
+			l.Info("Skipping the clean, %s", reason)
+			return nil
+		}
+		opts.yes = true
+		opts.reason = "schedule"
+	}
+	if *on != "" {
+		d, err := disk.Device(*on)
+		if err != nil {
+			return fmt.Errorf("cannot read the filesystem of %s (%s)", *on, err)
+		}
+		opts.device = &d
+	}
+
+	manifest, err := getManifest(l)
+	if err != nil {
+		return err
+	}
+	if !userFlags.enabled() {
+		cleanCaches(l, manifest, newPathContext(), opts)
+		return nil
+	}
+
+	selected, err := userFlags.users(l)
+	if err != nil {
+		return err
+	}
+	// each user is confirmed separately
+	for _, u := range selected {
+		l.Info("User %s (%s)", u.Name, u.Home)
+		opts.owner = u.Name
+		cleanCaches(l, manifest, userPathContext(u), opts)
+	}
+	return nil
+}
+
+type cleanOptions struct {
+	yes                bool
+	includeUserData    bool
+	includeUnknownRisk bool
+	// Only the caches on the filesystem of this device are cleaned, if set
+	device *uint64
+	// Why the clean runs and for whom, for the audit log
+	reason string
+	owner  string
+}
+
+func cleanCaches(l *log.Logger, manifest *apps.Manifest, ctx *path.PathContext, opts cleanOptions) {
+	results := scanManifest(l, manifest, ctx, "")
+	if n := unlocatedCaches(results); n > 0 {
+		l.Warn("%d caches could not be located, they need commands, which are disabled, and are not cleaned", n)
+	}
+	if opts.device != nil {
+		results = onDevice(l, results, *opts.device)
+	}
+
+	auditor := &auditor{manifest: manifest, guard: newGuard(ctx), reason: opts.reason, owner: opts.owner}
+	selected := cleanableCaches(l, results, auditor, opts)
+	if !opts.yes && !opts.includeUnknownRisk {
+		selected = confirmUnknownRisk(selected)
+	}
+	if len(selected) == 0 {
+		l.Info("Nothing to clean")
+		return
+	}
+
+	if !opts.yes && !confirmClean(selected) {
+		l.Info("Aborted")
+		return
+	}
+
+	var freed int64
+	for _, r := range selected {
+		for _, c := range r.caches {
+			l.Debug("Deleting %s", c.path)
+			if err := auditor.remove(l, r.app, c); err != nil {
+				l.Error("Error deleting %s: %s", c.path, err)
+				continue
+			}
+			l.Info("Deleted %s (%s)", c.path, io.HumanizeBytes(c.size))
+			freed += c.size
+		}
+	}
+	l.Info("Freed %s", io.HumanizeBytes(freed))
+}
+
+// Returns the caches of the results that may be deleted, skipping empty and
+// excluded ones, those refused by the guard, which the auditor records, and,
+// unless the options include them, those containing user data. Caches with
+// an unknown risk are skipped when the clean is not confirmed by the user,
+// unless the options include them.
+func cleanableCaches(l *log.Logger, results []appResult, auditor *auditor, opts cleanOptions) []appResult {
+	var selected []appResult
+	for _, r := range results {
+		kept := appResult{app: r.app, path: r.path}
+		for _, c := range r.caches {
+			if c.excluded != "" {
+				l.Info("Skipping %s, it is excluded by %s", c.path, c.excluded)
+				continue
+			}
+			if c.err != nil {
+				// reported by the scan
+				continue
+			}
+			if err := auditor.check(l, r.app, c); err != nil {
+				l.Warn("Skipping %s: %s", c.path, err)
+				continue
+			}
+			risk := r.app.CacheRisk(c.cache)
+			if risk == apps.RiskUserData && !opts.includeUserData {
+				l.Warn("Skipping %s, it contains user data (use -include-user-data to delete it)", c.path)
+				continue
+			}
+			if !risk.Known() && opts.yes && !opts.includeUnknownRisk {
+				l.Warn("Skipping %s, the cost of deleting it is unknown (use -include-unknown-risk to delete it)", c.path)
+				continue
+			}
+			if c.size > 0 {
+				kept.caches = append(kept.caches, c)
+			}
+		}
+		if len(kept.caches) > 0 {
+			selected = append(selected, kept)
+		}
+	}
+	return selected
+}
+
+// Caches may be deleted inside the cache directories of the user of ctx and
+// the allowed paths of the config, where ~ is the home of the user.
+func newGuard(ctx *path.PathContext) *safety.Guard {
+	var allowed []string
+	for _, p := range config.Runtime.AllowedPaths {
+		allowed = append(allowed, expandHome(ctx, p))
+	}
+	guard := safety.NewGuard(ctx.Dir("home"), ctx.Dir("xdg.cache"), ctx.Dir("tmp"), allowed)
+	for _, p := range config.Runtime.ProtectedPaths {
+		guard.Protected = append(guard.Protected, expandHome(ctx, p))
+	}
+	guard.Cwd, _ = os.Getwd()
+	return guard
+}
+
+// Shared so that input buffered by a confirmation is not lost for the next.
+var stdin = bufio.NewReader(os.Stdin)
+
+func riskColor(risk apps.Risk) ansi.Code {
+	switch risk {
+	case apps.RiskSafe:
+		return ansi.Green
+	case apps.RiskSlowRebuild:
+		return ansi.Yellow
+	case apps.RiskUserData:
+		return ansi.Red
+	default:
+		return ansi.Empty
+	}
+}
+
+// Asks the user whether the caches with an unknown risk may be deleted,
+// and returns the selection without them if not.
+func confirmUnknownRisk(selected []appResult) []appResult {
+	var unknown []string
+	for _, r := range selected {
+		for _, c := range r.caches {
+			if !r.app.CacheRisk(c.cache).Known() {
+				unknown = append(unknown, fmt.Sprintf("  %s  %s  %s", ansi.Str(r.app.Name).Style(ansi.Bold), c.path, io.HumanizeBytes(c.size)))
+			}
+		}
+	}
+	if len(unknown) == 0 {
+		return selected
+	}
+	fmt.Println("The manifest does not say what deleting these caches costs:")
+	for _, line := range unknown {
+		fmt.Println(line)
+	}
+	fmt.Print("Include them? [y/N] ")
+	if confirmed() {
+		return selected
+	}
+	var kept []appResult
+	for _, r := range selected {
+		known := appResult{app: r.app, path: r.path}
+		for _, c := range r.caches {
+			if r.app.CacheRisk(c.cache).Known() {
+				known.caches = append(known.caches, c)
+			}
+		}
+		if len(known.caches) > 0 {
+			kept = append(kept, known)
+		}
+	}
+	return kept
+}
+
+// Lists the caches about to be deleted with what is known about them and
+// asks the user to confirm.
+func confirmClean(selected []appResult) bool {
+	var total int64
+	var count int
+	fmt.Println("The following caches will be deleted:")
+	for _, r := range selected {
+		fmt.Printf("  %s%s\n", ansi.Str(r.app.Name).Style(ansi.Bold), describeApp(r.app))
+		for _, c := range r.caches {
+			risk := r.app.CacheRisk(c.cache)
+			fmt.Printf("    %s  %s  %s\n", c.path, io.HumanizeBytes(c.size), ansi.Str(risk.String()).Style(riskColor(risk)))
+			if c.cache.Description != "" {
+				fmt.Printf("      %s\n", c.cache.Description)
+			}
+			if docs := r.app.CacheDocs(c.cache); docs != "" {
+				fmt.Printf("      %s\n", ansi.Str("See "+docs).Style(ansi.Dim))
+			}
+			total += c.size
+			count++
+		}
+	}
+	fmt.Printf("Delete %d caches (%s)? [y/N] ", count, io.HumanizeBytes(total))
+	return confirmed()
+}
+
+// Reads a yes or no answer, no being the default.
+func confirmed() bool {
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
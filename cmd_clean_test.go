@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/audit"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/safety"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanableCachesAuditsRefusals(t *testing.T) {
+	l := log.New()
+	l.CurrentLevel = log.LevelNone
+	c := config.Default()
+	c.AuditLog = filepath.Join(t.TempDir(), "audit.jsonl")
+	withConfig(t, c)
+	home := t.TempDir()
+	cacheDir := filepath.Join(home, ".cache")
+	auditor := &auditor{manifest: &apps.Manifest{}, guard: safety.NewGuard(home, cacheDir, "", nil), reason: "clean"}
+	results := []appResult{{app: &apps.App{Name: "npm", Risk: apps.RiskSafe}, caches: []cacheResult{
+		{path: filepath.Join(cacheDir, "npm"), size: 10},
+		{path: filepath.Join(home, "Documents"), size: 20},
+	}}}
+
+	selected := cleanableCaches(l, results, auditor, cleanOptions{yes: true})
+	if assert.Len(t, selected, 1) && assert.Len(t, selected[0].caches, 1) {
+		assert.Equal(t, filepath.Join(cacheDir, "npm"), selected[0].caches[0].path)
+	}
+	records, err := audit.Query(c.AuditLog, audit.Filter{})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, filepath.Join(home, "Documents"), records[0].Path)
+		assert.Equal(t, audit.ResultRefused, records[0].Result)
+		assert.Contains(t, records[0].Error, "outside the allowed directories")
+	}
+}
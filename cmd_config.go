@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/ansi"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+)
+
+func runConfig(l *log.Logger, args []string) error {
+	if len(args) != 1 || args[0] != "show" {
+		return errors.New("usage: config show")
+	}
+	return runConfigShow()
+}
+
+// Prints the effective value of every setting and where it comes from.
+func runConfigShow() error {
+	if config.Runtime.File != "" {
+		fmt.Printf("Config file: %s\n\n", config.Runtime.File)
+	} else {
+		fmt.Printf("No config file\n\n")
+	}
+	for _, s := range config.Runtime.Settings() {
+		value := s.Value
+		if value == "" {
+			value = string(ansi.Str("(empty)").Style(ansi.Dim))
+		}
+		origin := string(s.Origin)
+		if s.Origin == config.OriginEnv {
+			origin += " " + s.Env
+		}
+		fmt.Printf("  %-26s %s %s\n", s.Key, value, ansi.Str("("+origin+")").Style(ansi.Dim))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/ansi"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+)
+
+// Evaluates a pattern and prints every step of its evaluation, to find out
+// why a manifest entry does not match.
+func runExplain(l *log.Logger, args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	appPath := flags.String("app-path", "", "value of {app_path}")
+	version := flags.String("version", "", "value of {version}")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: explain [-app-path path] [-version version] <pattern>")
+	}
+
+	ctx := newPathContext().ForApp(*appPath, *version)
+	trace, paths, err := path.PathPattern(flags.Arg(0)).Explain(ctx, path.WithLogger(l.Slog()))
+	printTrace(trace, "", "")
+	fmt.Println()
+	if err != nil {
+		return fmt.Errorf("pattern does not match (%s)", err)
+	}
+	for _, p := range paths {
+		fmt.Println(ansi.Str(p).Style(ansi.Bold))
+	}
+	return nil
+}
+
+func printTrace(t *path.Trace, prefix string, childPrefix string) {
+	var icon ansi.Str
+	switch t.Status {
+	case path.TraceAccepted:
+		icon = ansi.Str("✓").Style(ansi.Green)
+	case path.TraceRejected:
+		icon = ansi.Str("✗").Style(ansi.Red)
+	default:
+		icon = ansi.Str("•").Style(ansi.Dim)
+	}
+	// keep multi-line messages, e.g. command errors, inside the tree
+	message := strings.ReplaceAll(t.Message, "\n", "\n"+childPrefix+"  ")
+	fmt.Printf("%s%s %s\n", prefix, icon, message)
+	for i, child := range t.Children {
+		if i == len(t.Children)-1 {
+			printTrace(child, childPrefix+"└─ ", childPrefix+"   ")
+		} else {
+			printTrace(child, childPrefix+"├─ ", childPrefix+"│  ")
+		}
+	}
+}
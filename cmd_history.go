@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/ansi"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/growth"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+)
+
+// Shows how the disk usage of the caches evolved over the recorded scans.
+func runHistory(l *log.Logger, args []string) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	caches := flags.Bool("caches", false, "show caches instead of apps")
+	system := flags.String("system", "", "user or root of the scans to show (default the current system)")
+	limit := flags.Int("n", 20, "maximum number of rows")
+	points := flags.Int("points", 24, "number of scans in the sparklines")
+	flags.Parse(args)
+
+	snapshots, err := growth.Load(config.GetUsageHistoryPath(), *system)
+	if err != nil {
+		return fmt.Errorf("error reading the history (%s)", err)
+	}
+	if len(snapshots) == 0 {
+		l.Info("No scan recorded yet, run scan first")
+		return nil
+	}
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	if len(snapshots) == 1 {
+		fmt.Printf("1 scan on %s\n\n", first.Time.Format("2006-01-02"))
+	} else {
+		fmt.Printf("%d scans from %s to %s\n\n", len(snapshots), first.Time.Format("2006-01-02"), last.Time.Format("2006-01-02"))
+	}
+
+	kind, sizes := "App", func(s growth.Snapshot) map[string]int64 { return s.Apps }
+	if *caches {
+		kind, sizes = "Cache", func(s growth.Snapshot) map[string]int64 { return s.Caches }
+	}
+	trends := growth.Trends(snapshots, sizes)
+	trends = trends[:min(*limit, len(trends))]
+	width := len(kind)
+	for _, t := range trends {
+		width = max(width, len(t.Name))
+	}
+	fmt.Printf("  %-*s  %-*s  %10s  %10s  %10s\n", width, kind, *points, "Trend", "Size", "Per week", "Last scan")
+	for _, t := range trends {
+		sizes := t.Sizes[max(0, len(t.Sizes)-*points):]
+		fmt.Printf("  %-*s  %s%*s  %10s  %s  %s\n", width, t.Name,
+			ansi.Sparkline(sizes).Style(ansi.Cyan), *points-len(sizes), "",
+			io.HumanizeBytes(t.Current()), signedBytes(t.WeeklyGrowth), signedBytes(t.Change))
+	}
+
+	movers := growth.BiggestMovers(growth.Trends(snapshots, func(s growth.Snapshot) map[string]int64 { return s.Caches }), 5)
+	if len(movers) > 0 {
+		fmt.Printf("\nBiggest movers since the previous scan:\n")
+		for _, t := range movers {
+			fmt.Printf("  %s  %s\n", signedBytes(t.Change), t.Name)
+		}
+	}
+	return nil
+}
+
+// Formats a change of size in 10 columns, growth in red and shrinking in
+// green.
+func signedBytes(n int64) ansi.Str {
+	switch {
+	case n > 0:
+		return ansi.Str(fmt.Sprintf("%10s", "+"+io.HumanizeBytes(n))).Style(ansi.Red)
+	case n < 0:
+		return ansi.Str(fmt.Sprintf("%10s", "-"+io.HumanizeBytes(-n))).Style(ansi.Green)
+	default:
+		return ansi.Str(fmt.Sprintf("%10s", "0 B")).Style(ansi.Dim)
+	}
+}
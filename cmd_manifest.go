@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+)
+
+func runManifest(l *log.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: manifest convert [-to json|yaml|toml] <input> [output]")
+	}
+	switch args[0] {
+	case "convert":
+		return runManifestConvert(l, args[1:])
+	default:
+		return fmt.Errorf("unknown manifest command %s", args[0])
+	}
+}
+
+// Converts a manifest file between formats. The output format is taken from
+// -to, or from the extension of the output file. Without an output file the
+// converted manifest is written to stdout.
+func runManifestConvert(l *log.Logger, args []string) error {
+	flags := flag.NewFlagSet("manifest convert", flag.ExitOnError)
+	to := flags.String("to", "", "output format (json, yaml or toml)")
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return errors.New("usage: manifest convert [-to json|yaml|toml] <input> [output]")
+	}
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	var format apps.ManifestFormat
+	var err error
+	switch {
+	case *to != "":
+		format, err = apps.ParseManifestFormat(*to)
+	case output != "":
+		format, err = apps.FormatFromPath(output)
+	default:
+		format = apps.FormatJSON
+	}
+	if err != nil {
+		return err
+	}
+
+	manifest, err := apps.ReadManifestFile(input)
+	if err != nil {
+		return err
+	}
+	data, err := apps.EncodeManifest(manifest, format)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	l.Debug("Writing %s manifest to %s", format, output)
+	return os.WriteFile(output, data, 0644)
+}
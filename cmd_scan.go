@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/disk"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/growth"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+)
+
+func getManifest(l *log.Logger) (*apps.Manifest, error) {
+	l.Debug("Getting manifest...")
+	timer := time.Now()
+	manifest, err := apps.GetManifest(l)
+	took := time.Since(timer)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching manifest: %s", err)
+	}
+	l.Debug("Manifest fetched (took %s)", took)
+	return manifest, nil
+}
+
+// Variables set with repeated -env KEY=VALUE flags.
+type envFlag map[string]string
+
+func (e envFlag) String() string {
+	var vars []string
+	for k, v := range e {
+		vars = append(vars, k+"="+v)
+	}
+	return strings.Join(vars, ",")
+}
+
+func (e envFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %s", s)
+	}
+	e[k] = v
+	return nil
+}
+
+func runScan(l *log.Logger, args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ExitOnError)
+	flags.String("root", "", "scan another system mounted at this directory, e.g. a disk image or a container rootfs")
+	home := flags.String("home", "", "home directory of the user to scan with -root")
+	goos := flags.String("os", "", "operating system of the system scanned with -root (default current)")
+	goarch := flags.String("arch", "", "architecture of the system scanned with -root (default current)")
+	env := envFlag{}
+	flags.Var(env, "env", "environment variable KEY=VALUE of the system scanned with -root, can be repeated")
+	flags.String("format", "", "output format, text or json")
+	userFlags := addUserFlags(flags)
+	flags.Parse(args)
+	if err := applyFlags(flags, map[string]string{"root": "scan.roots", "format": "output"}); err != nil {
+		return err
+	}
+	roots := config.Runtime.ScanRoots
+	if config.Runtime.Output == "json" {
+		// keep stdout for the report
+		l = &log.Logger{CurrentLevel: max(l.CurrentLevel, log.LevelError)}
+	}
+
+	var targets []scanTarget
+	switch {
+	case userFlags.enabled():
+		if flagSet(flags, "root") {
+			return errors.New("-root cannot be used with -all-users or -users")
+		}
+		selected, err := userFlags.users(l)
+		if err != nil {
+			return err
+		}
+		for _, u := range selected {
+			targets = append(targets, scanTarget{user: u.Name, ctx: userPathContext(u)})
+		}
+	case len(roots) > 0:
+		if *home == "" {
+			l.Warn("No -home given, patterns using the home directory will not match")
+		}
+		for _, root := range roots {
+			targets = append(targets, scanTarget{root: root, ctx: path.NewSyntheticPathContext(*home, *goos, *goarch, env)})
+		}
+	case *home != "" || *goos != "" || *goarch != "" || len(env) > 0:
+		return errors.New("-home, -os, -arch and -env can only be used with -root")
+	default:
+		targets = append(targets, scanTarget{ctx: newPathContext()})
+	}
+
+	manifest, err := getManifest(l)
+	if err != nil {
+		return err
+	}
+	report := scanReport{Mounts: []mountReport{}}
+	for _, target := range targets {
+		switch {
+		case target.user != "":
+			l.Info("User %s", target.user)
+		case target.root != "":
+			l.Info("System at %s", target.root)
+		}
+		report.add(target, scanManifest(l, manifest, target.ctx, target.root))
+	}
+	report.record(l)
+	if config.Runtime.Output == "json" {
+		return report.printJSON()
+	}
+	if len(report.Scans) > 1 {
+		fmt.Println()
+		for _, scan := range report.Scans {
+			fmt.Printf("  %-24s %10s\n", scan.name(), io.HumanizeBytes(scan.Total))
+		}
+	}
+	if len(report.Mounts) > 0 {
+		fmt.Println()
+		for _, mount := range report.Mounts {
+			fmt.Printf("  %-24s %10s of caches, %s free of %s\n", mount.Path, io.HumanizeBytes(mount.Caches), io.HumanizeBytes(mount.Free), io.HumanizeBytes(mount.Total))
+		}
+	}
+	l.Info("Total disk usage: %s", io.HumanizeBytes(report.Total))
+	for _, scan := range report.Scans {
+		switch {
+		case scan.Unreadable == 0:
+		case scan.name() != "":
+			l.Warn("%d caches of %s could not be read, the results are partial", scan.Unreadable, scan.name())
+		default:
+			l.Warn("%d caches could not be read, the results are partial", scan.Unreadable)
+		}
+		switch {
+		case scan.Unlocated == 0:
+		case scan.name() != "":
+			l.Warn("%d caches of %s could not be located, they need commands, which are not run for other users and systems, the results are partial", scan.Unlocated, scan.name())
+		default:
+			l.Warn("%d caches could not be located, they need commands, which are disabled in safe mode, the results are partial", scan.Unlocated)
+		}
+	}
+	return nil
+}
+
+// A system, or a user of this system, to scan.
+type scanTarget struct {
+	user string
+	root string
+	ctx  *path.PathContext
+}
+
+type scanReport struct {
+	Scans []systemReport `json:"scans"`
+	Total int64          `json:"total"`
+	// Filesystems the caches are on
+	Mounts []mountReport `json:"mounts"`
+
+	mounts map[uint64]int
+}
+
+type mountReport struct {
+	Path  string `json:"path"`
+	Total int64  `json:"total"`
+	Free  int64  `json:"free"`
+	// Disk usage of the caches on the filesystem
+	Caches int64 `json:"caches"`
+}
+
+type systemReport struct {
+	User  string      `json:"user,omitempty"`
+	Root  string      `json:"root,omitempty"`
+	Apps  []appReport `json:"apps"`
+	Total int64       `json:"total"`
+	// Number of caches that could not be read, which the total is missing
+	Unreadable int `json:"unreadable,omitempty"`
+	// Number of caches that could not be located because commands are
+	// disabled, which the total is missing
+	Unlocated int `json:"unlocated,omitempty"`
+}
+
+type appReport struct {
+	Name   string        `json:"name"`
+	Path   string        `json:"path"`
+	Caches []cacheReport `json:"caches"`
+	// Exclusion rule of the config matching the app
+	Excluded string `json:"excluded,omitempty"`
+	// Number of caches that could not be located because commands are
+	// disabled
+	Unlocated int `json:"unlocated,omitempty"`
+}
+
+type cacheReport struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Risk     apps.Risk `json:"risk,omitempty"`
+	Mount    string    `json:"mount,omitempty"`
+	Excluded string    `json:"excluded,omitempty"`
+	// Why the cache could not be read
+	Error string `json:"error,omitempty"`
+}
+
+func (r *scanReport) add(target scanTarget, results []appResult) {
+	system := systemReport{User: target.user, Root: target.root, Apps: []appReport{}}
+	for _, result := range results {
+		app := appReport{Name: result.app.Name, Path: result.path, Caches: []cacheReport{}, Excluded: result.excluded, Unlocated: result.unlocated}
+		system.Unlocated += result.unlocated
+		for _, c := range result.caches {
+			cache := cacheReport{Path: c.path, Size: c.size, Risk: result.app.CacheRisk(c.cache), Excluded: c.excluded}
+			if c.err != nil {
+				cache.Error = c.err.Error()
+				system.Unreadable++
+			} else if mount := r.mount(c); mount != nil {
+				cache.Mount = mount.Path
+				mount.Caches += c.size
+			}
+			app.Caches = append(app.Caches, cache)
+		}
+		system.Apps = append(system.Apps, app)
+		system.Total += result.size()
+	}
+	r.Scans = append(r.Scans, system)
+	r.Total += system.Total
+}
+
+// Returns the filesystem of a cache, nil if it is excluded or unknown.
+func (r *scanReport) mount(c cacheResult) *mountReport {
+	if c.excluded != "" {
+		return nil
+	}
+	if i, ok := r.mounts[c.device]; ok {
+		return &r.Mounts[i]
+	}
+	// the device is that of the link for linked caches
+	mountPoint, err := disk.LinkMountPoint(c.path)
+	if err != nil {
+		return nil
+	}
+	usage, err := disk.GetUsage(mountPoint)
+	if err != nil {
+		return nil
+	}
+	if r.mounts == nil {
+		r.mounts = map[uint64]int{}
+	}
+	r.mounts[c.device] = len(r.Mounts)
+	r.Mounts = append(r.Mounts, mountReport{Path: mountPoint, Total: usage.Total, Free: usage.Free})
+	return &r.Mounts[len(r.Mounts)-1]
+}
+
+// Appends the disk usage of the caches to the usage history.
+func (r *scanReport) record(l *log.Logger) {
+	now := time.Now()
+	for _, scan := range r.Scans {
+		snapshot := growth.Snapshot{Time: now, System: scan.name(), Apps: map[string]int64{}, Caches: map[string]int64{}}
+		for _, app := range scan.Apps {
+			if app.Excluded != "" {
+				continue
+			}
+			// excluded and unreadable caches are left out rather than
+			// recorded as empty, which would look like they shrank
+			for _, c := range app.Caches {
+				if c.Excluded == "" && c.Error == "" {
+					snapshot.Apps[app.Name] += c.Size
+					snapshot.Caches[c.Path] += c.Size
+				}
+			}
+		}
+		if err := growth.Append(config.GetUsageHistoryPath(), snapshot); err != nil {
+			l.Warn("Could not record the disk usage in the history: %s", err)
+			return
+		}
+	}
+}
+
+func (r *systemReport) name() string {
+	if r.User != "" {
+		return r.User
+	}
+	return r.Root
+}
+
+func (r *scanReport) printJSON() error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
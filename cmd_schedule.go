@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/ansi"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/schedule"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/system"
+)
+
+const scheduleUsage = "usage: schedule install|remove|status [-backend systemd|cron]"
+
+// Manages a weekly `clean -scheduled`, run by a systemd user timer or by
+// cron when systemd is not running.
+func runSchedule(l *log.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(scheduleUsage)
+	}
+	flags := flag.NewFlagSet("schedule "+args[0], flag.ExitOnError)
+	backend := flags.String("backend", "", "systemd or cron (default systemd when it is running)")
+	flags.Parse(args[1:])
+	if flags.NArg() != 0 {
+		return errors.New(scheduleUsage)
+	}
+	s, err := newSchedule(*backend)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "install":
+		if err := s.Install(); err != nil {
+			return fmt.Errorf("error installing the schedule (%s)", err)
+		}
+		l.Info("Caches will be cleaned weekly by %s, see %s for the results", s.Backend, s.LogFile)
+	case "remove":
+		if err := s.Remove(); err != nil {
+			return fmt.Errorf("error removing the schedule (%s)", err)
+		}
+		l.Info("Caches will no longer be cleaned by %s", s.Backend)
+	case "status":
+		return printScheduleStatus(s)
+	default:
+		return fmt.Errorf("unknown schedule command %s", args[0])
+	}
+	return nil
+}
+
+func newSchedule(backend string) (*schedule.Schedule, error) {
+	var b schedule.Backend
+	var err error
+	if backend != "" {
+		b, err = schedule.ParseBackend(backend)
+	} else {
+		b, err = schedule.DetectBackend()
+	}
+	if err != nil {
+		return nil, err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return &schedule.Schedule{
+		Backend: b,
+		Exe:     exe,
+		LogFile: config.GetScheduleLogPath(),
+		UnitDir: filepath.Join(xdg.ConfigHome, "systemd", "user"),
+	}, nil
+}
+
+// Number of lines of the log printed by schedule status.
+const scheduleLogLines = 10
+
+func printScheduleStatus(s *schedule.Schedule) error {
+	installed, details, err := s.Status()
+	if err != nil {
+		return err
+	}
+	if !installed {
+		fmt.Printf("Not scheduled with %s\n", s.Backend)
+		return nil
+	}
+	fmt.Printf("Scheduled weekly with %s\n", s.Backend)
+	if details != "" {
+		fmt.Println(ansi.Str(details).Style(ansi.Dim))
+	}
+	data, err := os.ReadFile(s.LogFile)
+	if err != nil {
+		fmt.Printf("\nNo clean ran yet\n")
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	fmt.Printf("\nLast lines of %s:\n", s.LogFile)
+	for _, line := range lines[max(0, len(lines)-scheduleLogLines):] {
+		fmt.Printf("  %s\n", line)
+	}
+	return nil
+}
+
+// Returns why a scheduled clean should not run now, or an empty string.
+func skipScheduledClean(l *log.Logger) string {
+	if !config.Runtime.ScheduleOnBattery {
+		onBattery, err := system.OnBattery()
+		if err != nil {
+			l.Warn("Cannot tell whether the machine runs on battery: %s", err)
+		} else if onBattery {
+			return "the machine runs on battery"
+		}
+	}
+	load, err := system.LoadPerCPU()
+	if err != nil {
+		l.Warn("Cannot read the load average: %s", err)
+	} else if load > config.Runtime.ScheduleMaxLoad {
+		return fmt.Sprintf("the load average is %.2f per CPU, above %g", load, config.Runtime.ScheduleMaxLoad)
+	}
+	return ""
+}
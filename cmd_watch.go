@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/watch"
+)
+
+// Watches the free space of filesystems and cleans the caches on those
+// running low, e.g. on build agents filling up in the middle of a pipeline.
+func runWatch(l *log.Logger, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	flags.String("mounts", "", "comma separated paths of the filesystems to watch (default /)")
+	flags.String("min-free", "", "free space below which caches are cleaned, e.g. 10% or 5G (default 10%)")
+	flags.String("target-free", "", "free space to reach when cleaning (default 20%)")
+	flags.String("interval", "", "time between two checks (default 1m)")
+	flags.String("cooldown", "", "minimum time between two cleans of a filesystem (default 15m)")
+	once := flags.Bool("once", false, "check once and exit")
+	flags.Parse(args)
+	if err := applyFlags(flags, map[string]string{
+		"mounts":      "watch.mounts",
+		"min-free":    "watch.min_free",
+		"target-free": "watch.target_free",
+		"interval":    "watch.interval",
+		"cooldown":    "watch.cooldown",
+	}); err != nil {
+		return err
+	}
+
+	eventLog := config.Runtime.WatchEventLog
+	if eventLog == "" {
+		eventLog = config.GetWatchEventLogPath()
+	}
+	if err := os.MkdirAll(filepath.Dir(eventLog), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(eventLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	events := slog.New(log.Tee(slog.NewJSONHandler(file, nil), l.Slog().Handler()))
+
+	manifest, err := getManifest(l)
+	if err != nil {
+		return err
+	}
+	ctx := newPathContext()
+	guard := newGuard(ctx)
+	// scans are reported through the events
+	quiet := &log.Logger{CurrentLevel: max(l.CurrentLevel, log.LevelWarn)}
+	auditor := &auditor{manifest: manifest, guard: guard, reason: "watch"}
+	scanned := map[string]cacheResult{}
+	scannedApps := map[string]*apps.App{}
+	w := &watch.Watcher{
+		Mounts:     config.Runtime.WatchMounts,
+		MinFree:    config.Runtime.WatchMinFree,
+		TargetFree: config.Runtime.WatchTargetFree,
+		Cooldown:   config.Runtime.WatchCooldown,
+		Candidates: func() ([]watch.Candidate, error) {
+			manifest = refreshManifest(quiet, events, manifest)
+			auditor.manifest = manifest
+			results := scanManifest(quiet, manifest, ctx, "")
+			var candidates []watch.Candidate
+			risks := map[string]apps.Risk{}
+			for _, r := range cleanableCaches(quiet, results, auditor, cleanOptions{
+				yes:                true,
+				includeUserData:    config.Runtime.IncludeUserData,
+				includeUnknownRisk: config.Runtime.IncludeUnknownRisk,
+			}) {
+				for _, c := range r.caches {
+					scanned[c.path] = c
+					scannedApps[c.path] = r.app
+					risks[c.path] = r.app.CacheRisk(c.cache)
+					candidates = append(candidates, watch.Candidate{Path: c.path, Size: c.size, App: r.app.Name, Device: c.device})
+				}
+			}
+			// the cheapest to rebuild first, then the largest
+			sort.SliceStable(candidates, func(i, j int) bool {
+				ri, rj := riskRank(risks[candidates[i].Path]), riskRank(risks[candidates[j].Path])
+				if ri != rj {
+					return ri < rj
+				}
+				return candidates[i].Size > candidates[j].Size
+			})
+			return candidates, nil
+		},
+		Remove: func(c watch.Candidate) error {
+			return auditor.remove(quiet, scannedApps[c.Path], scanned[c.Path])
+		},
+		Events: events,
+	}
+
+	if *once {
+		w.Check()
+		return nil
+	}
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return w.Run(sigCtx, config.Runtime.WatchInterval)
+}
+
+// Returns the manifest to clean with, the local manifest being refreshed
+// once manifest.ttl has elapsed, or the previous one if it cannot be read.
+func refreshManifest(l *log.Logger, events *slog.Logger, previous *apps.Manifest) *apps.Manifest {
+	manifest, err := getManifest(l)
+	if err != nil {
+		events.Warn("manifest_refresh_failed", "error", err.Error())
+		return previous
+	}
+	return manifest
+}
+
+// Orders risks by increasing cost of deleting the cache, caches of an
+// unknown risk being deleted last.
+func riskRank(risk apps.Risk) int {
+	switch risk {
+	case apps.RiskSafe:
+		return 0
+	case apps.RiskSlowRebuild:
+		return 1
+	case apps.RiskUserData:
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRiskRank(t *testing.T) {
+	ordered := []apps.Risk{apps.RiskSafe, apps.RiskSlowRebuild, apps.RiskUserData, ""}
+	for i := 1; i < len(ordered); i++ {
+		assert.Less(t, riskRank(ordered[i-1]), riskRank(ordered[i]), ordered[i])
+	}
+	assert.Equal(t, riskRank(""), riskRank(apps.Risk("unheard_of")))
+}
+
+func TestRefreshManifest(t *testing.T) {
+	l := log.New()
+	l.CurrentLevel = log.LevelNone
+	dataHome, configHome := xdg.DataHome, xdg.ConfigHome
+	xdg.DataHome, xdg.ConfigHome = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { xdg.DataHome, xdg.ConfigHome = dataHome, configHome })
+	remote := filepath.Join(t.TempDir(), "manifest.json")
+	assert.NoError(t, os.WriteFile(remote, []byte(`{"apps":[{"name":"new","path":"/","caches":[]}],"version":2}`), 0644))
+	c := config.Default()
+	c.ManifestUrl = (&url.URL{Scheme: "file", Path: "/" + strings.TrimPrefix(filepath.ToSlash(remote), "/")}).String()
+	// expired right away
+	c.ManifestTtl = 0
+	withConfig(t, c)
+	var events bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&events, nil))
+	previous := &apps.Manifest{Apps: []apps.App{{Name: "old"}}}
+
+	manifest := refreshManifest(l, logger, previous)
+	if assert.Len(t, manifest.Apps, 1) {
+		assert.Equal(t, "new", manifest.Apps[0].Name)
+	}
+	assert.Empty(t, events.String())
+
+	assert.NoError(t, os.Remove(remote))
+	assert.Same(t, manifest, refreshManifest(l, logger, manifest))
+	assert.Contains(t, events.String(), "manifest_refresh_failed")
+}
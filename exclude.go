@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+)
+
+// Returns the exclusion rule of the config matching the app, or an empty
+// string.
+func excludedApp(app *apps.App) string {
+	for _, name := range config.Runtime.ExcludedApps {
+		if strings.EqualFold(name, app.Name) {
+			return "exclude.apps=" + name
+		}
+	}
+	for _, category := range config.Runtime.ExcludedCategories {
+		if app.Category != "" && strings.EqualFold(category, string(app.Category)) {
+			return "exclude.categories=" + category
+		}
+	}
+	return ""
+}
+
+// Replaces a leading ~ of a path of the config with the home directory of
+// ctx.
+func expandHome(ctx *path.PathContext, p string) string {
+	if home := ctx.Dir("home"); home != "" && (p == "~" || strings.HasPrefix(p, "~/")) {
+		return filepath.Join(home, p[1:])
+	}
+	return p
+}
+
+// Returns the exclusion rule of the config matching the cache path p, one of
+// its parent directories or a path inside it, or an empty string. A leading ~
+// in globs is the home directory of ctx.
+func excludedPath(ctx *path.PathContext, p string) string {
+	for _, glob := range config.Runtime.ExcludedPaths {
+		expanded := filepath.ToSlash(expandHome(ctx, glob))
+		// deleting the cache would delete what is excluded inside it
+		if path.MatchGlobBelow(expanded, filepath.ToSlash(p)) {
+			return "exclude.paths=" + glob
+		}
+		for dir := filepath.Clean(p); ; dir = filepath.Dir(dir) {
+			if path.MatchGlob(expanded, filepath.ToSlash(dir)) {
+				return "exclude.paths=" + glob
+			}
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+	"github.com/stretchr/testify/assert"
+)
+
+func withConfig(t *testing.T, c config.RuntimeConfig) {
+	old := config.Runtime
+	config.Runtime = c
+	t.Cleanup(func() { config.Runtime = old })
+}
+
+func TestExcludedApp(t *testing.T) {
+	c := config.Default()
+	c.ExcludedApps = []string{"Docker"}
+	c.ExcludedCategories = []string{"ide"}
+	withConfig(t, c)
+	for _, test := range []struct {
+		app  apps.App
+		rule string
+	}{
+		{apps.App{Name: "Docker"}, "exclude.apps=Docker"},
+		{apps.App{Name: "docker", Category: apps.CategoryContainer}, "exclude.apps=Docker"},
+		{apps.App{Name: "VS Code", Category: apps.CategoryIDE}, "exclude.categories=ide"},
+		{apps.App{Name: "Gradle", Category: apps.CategoryPackageManager}, ""},
+		{apps.App{Name: "Unknown"}, ""},
+	} {
+		assert.Equal(t, test.rule, excludedApp(&test.app), test.app.Name)
+	}
+}
+
+func TestExcludedPath(t *testing.T) {
+	c := config.Default()
+	c.ExcludedPaths = []string{"~/.gradle", "/opt/*/cache", "**/node_modules", "/srv/cache/keep"}
+	withConfig(t, c)
+	ctx := path.NewSyntheticPathContext("/home/me", "linux", "", nil)
+	for p, rule := range map[string]string{
+		"/home/me/.gradle":                    "exclude.paths=~/.gradle",
+		"/home/me/.gradle/caches":             "exclude.paths=~/.gradle",
+		"/home/me/.gradle/caches/8.5/":        "exclude.paths=~/.gradle",
+		"/home/me/.gradlew":                   "",
+		"/home/other/.gradle":                 "",
+		"/opt/ci/cache":                       "exclude.paths=/opt/*/cache",
+		"/opt/ci/cache/bazel":                 "exclude.paths=/opt/*/cache",
+		"/opt/ci/runner/cache":                "",
+		"/home/me/src/app/node_modules/.vite": "exclude.paths=**/node_modules",
+		"/home/me/.cache/pip":                 "",
+		// contains an excluded directory
+		"/srv/cache":       "exclude.paths=/srv/cache/keep",
+		"/srv/cache/keep":  "exclude.paths=/srv/cache/keep",
+		"/srv/cache/other": "",
+		"/opt":             "exclude.paths=/opt/*/cache",
+	} {
+		assert.Equal(t, rule, excludedPath(ctx, p), p)
+	}
+}
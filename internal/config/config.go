@@ -1,61 +1,246 @@
 package config
 
 import (
-	"fmt"
+	"errors"
 	"os"
 	"path"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/adrg/xdg"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/disk"
 )
 
 func GetLocalManifestPath() string {
 	return path.Join(xdg.DataHome, "devcleaner", "manifest.json")
 }
 
+// Directory containing user-written manifest fragments, merged on top of the
+// remote manifest.
+func GetManifestFragmentsDir() string {
+	return path.Join(xdg.ConfigHome, "devcleaner", "manifest.d")
+}
+
+// Disk usage of the caches at each scan, one JSON object per line.
+func GetUsageHistoryPath() string {
+	return path.Join(xdg.DataHome, "devcleaner", "usage.jsonl")
+}
+
+// Log of every deletion, one JSON object per line.
+func GetAuditLogPath() string {
+	return path.Join(xdg.StateHome, "devcleaner", "audit.jsonl")
+}
+
+// Structured log of the events of the watch command, one JSON object per
+// line.
+func GetWatchEventLogPath() string {
+	return path.Join(xdg.StateHome, "devcleaner", "watch.jsonl")
+}
+
+// Log file of the scheduled cleans.
+func GetScheduleLogPath() string {
+	return path.Join(xdg.StateHome, "devcleaner", "clean.log")
+}
+
 type RuntimeConfig struct {
 	ManifestUrl string
-	ManifestTtl time.Duration
+	// Tried in order when ManifestUrl cannot be fetched
+	ManifestMirrors []string
+	ManifestTtl     time.Duration
+	// PEM file of additional certificate authorities to trust
+	CABundle string
+	// Proxy for all requests, the standard proxy environment variables are
+	// used when empty
+	Proxy       string
+	HttpTimeout time.Duration
 	LogLevel    string
+	// Disables running tools to locate caches
+	SafeMode       bool
+	CommandTimeout time.Duration
+	// Prefixes of the commands the remote manifest may run, local manifest
+	// fragments may run any command
+	AllowedCommands []string
+	// Names of apps that are never cleaned
+	ExcludedApps []string
+	// Categories of apps that are never cleaned
+	ExcludedCategories []string
+	// Globs of cache paths that are never cleaned
+	ExcludedPaths []string
+	// Paths that are never deleted
+	ProtectedPaths []string
+	// Directories outside of the user directories in which caches may be
+	// deleted
+	AllowedPaths []string
+	// Systems mounted at these directories are scanned instead of this one
+	ScanRoots []string
+	// Whether clean deletes caches containing user data
+	IncludeUserData bool
+	// Whether caches with no or an unknown risk are deleted without asking
+	IncludeUnknownRisk bool
+	// text or json
+	Output string
+	// Load average per CPU above which scheduled cleans do not run
+	ScheduleMaxLoad float64
+	// Whether scheduled cleans run on battery
+	ScheduleOnBattery bool
+	// Paths of the filesystems watched by the watch command
+	WatchMounts []string
+	// The watch command cleans when the free space falls below WatchMinFree,
+	// until it reaches WatchTargetFree
+	WatchMinFree    disk.Threshold
+	WatchTargetFree disk.Threshold
+	WatchInterval   time.Duration
+	// Minimum time between two cleans of a filesystem
+	WatchCooldown time.Duration
+	// Defaults to GetWatchEventLogPath
+	WatchEventLog string
+	// Defaults to GetAuditLogPath
+	AuditLog string
+
+	// Config file that was loaded, if any
+	File    string
+	origins map[string]Origin
 }
 
-var Runtime = RuntimeConfig{
-	ManifestUrl: defaultManifestUrl,
-	ManifestTtl: defaultLocalManifestTTL,
-	LogLevel:    defaultLogLevel,
+// Where the value of a setting comes from, by increasing precedence.
+type Origin string
+
+const (
+	OriginDefault = Origin("default")
+	OriginFile    = Origin("file")
+	OriginEnv     = Origin("env")
+	OriginFlag    = Origin("flag")
+)
+
+// The configuration of the running program. It holds the defaults until the
+// program calls Load.
+var Runtime = Default()
+
+// Returns the default configuration, without reading the environment.
+func Default() RuntimeConfig {
+	return RuntimeConfig{
+		ManifestUrl:     defaultManifestUrl,
+		ManifestTtl:     defaultLocalManifestTTL,
+		HttpTimeout:     defaultHttpTimeout,
+		LogLevel:        defaultLogLevel,
+		CommandTimeout:  defaultCommandTimeout,
+		AllowedCommands: slices.Clone(defaultAllowedCommands),
+		Output:          defaultOutput,
+		ScheduleMaxLoad: defaultScheduleMaxLoad,
+		WatchMounts:     []string{"/"},
+		WatchMinFree:    disk.Threshold{Percent: defaultWatchMinFreePercent},
+		WatchTargetFree: disk.Threshold{Percent: defaultWatchTargetFreePercent},
+		WatchInterval:   defaultWatchInterval,
+		WatchCooldown:   defaultWatchCooldown,
+		origins:         map[string]Origin{},
+	}
 }
 
 const defaultLogLevel = "INFO"
 const defaultManifestUrl = "https://sao.gaetans.dev/manifest.json"
 const defaultLocalManifestTTL = time.Hour * 24
+const defaultHttpTimeout = time.Second * 30
+const defaultCommandTimeout = time.Second * 5
+const defaultOutput = "text"
+const defaultScheduleMaxLoad = 0.75
+const defaultWatchMinFreePercent = 10
+const defaultWatchTargetFreePercent = 20
+const defaultWatchInterval = time.Minute
+const defaultWatchCooldown = time.Minute * 15
+
+// Commands querying where tools keep their caches and their versions, which
+// may only be followed by positional arguments. Prefixes running arbitrary
+// code, e.g. `go run` or `npm exec`, must not be added.
+var defaultAllowedCommands = []string{
+	"which",
+	"go env", "go version",
+	"npm config get", "npm --version",
+	"yarn cache dir", "yarn config get", "yarn --version",
+	"pnpm store path", "pnpm --version",
+	"pip cache dir", "pip3 cache dir", "pip --version", "pip3 --version",
+	"brew --cache", "brew --prefix", "brew --version",
+	"cargo --version", "rustup --version",
+	"gradle --version", "mvn --version",
+	"flutter --version", "dart --version", "dotnet --version",
+}
 
-const ansiRed = "\033[31m"
-const ansiReset = "\033[0m"
+// Loads the configuration from the config file and the environment, see
+// LoadFrom.
+func Load() (*RuntimeConfig, error) {
+	return LoadFrom(configFilePath(), os.Environ())
+}
+
+// Loads the configuration from a config file, which is skipped if empty, and
+// from environment variables given as KEY=VALUE, on top of the defaults.
+// Invalid settings are reported as *ValidationError, possibly joined, and
+// unreadable files as *FileError.
+func LoadFrom(file string, environ []string) (*RuntimeConfig, error) {
+	c := Default()
+	if err := c.loadFile(file); err != nil {
+		return nil, err
+	}
+	if err := c.loadEnv(environ); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Returns where the value of a setting comes from.
+func (c *RuntimeConfig) Origin(key string) Origin {
+	if origin, ok := c.origins[key]; ok {
+		return origin
+	}
+	return OriginDefault
+}
+
+// Sets a setting from a command line flag, which takes precedence over
+// every other origin.
+func (c *RuntimeConfig) SetFlag(key string, value string) error {
+	s, err := lookupSetting(key)
+	if err != nil {
+		return &ValidationError{Key: key, Value: value, Origin: OriginFlag, Err: err}
+	}
+	return c.set(s, value, OriginFlag, "")
+}
 
-func invalidConfigError(name string, value string) {
-	fmt.Printf("%sInvalid %s: %s%s\n", ansiRed, name, value, ansiReset)
-	os.Exit(1)
+// source is the env variable or the file the value comes from.
+func (c *RuntimeConfig) set(s *setting, value string, origin Origin, source string) error {
+	if err := s.set(c, value); err != nil {
+		return &ValidationError{Key: s.key, Value: value, Origin: origin, Source: source, Err: err}
+	}
+	if c.origins == nil {
+		c.origins = map[string]Origin{}
+	}
+	c.origins[s.key] = origin
+	return nil
 }
 
-func init() {
-	environ := os.Environ()
+func (c *RuntimeConfig) loadEnv(environ []string) error {
+	var errs []error
 	for _, env := range environ {
-		parts := strings.SplitN(env, "=", 2)
-		if parts[1] == "" {
+		name, value, _ := strings.Cut(env, "=")
+		if value == "" {
 			continue
 		}
-
-		if parts[0] == "DEVCLEANER_MANIFEST_URL" {
-			Runtime.ManifestUrl = parts[1]
-		} else if parts[0] == "DEVCLEANER_MANIFEST_TTL" {
-			ttl, err := time.ParseDuration(parts[1])
-			if err != nil {
-				invalidConfigError("manifest ttl", parts[1])
+		for i := range settings {
+			if settings[i].env == name {
+				if err := c.set(&settings[i], value, OriginEnv, name); err != nil {
+					errs = append(errs, err)
+				}
 			}
-			Runtime.ManifestTtl = ttl
-		} else if parts[0] == "DEVCLEANER_LOGLEVEL" {
-			Runtime.LogLevel = parts[1]
 		}
 	}
+	return errors.Join(errs...)
+}
+
+// Splits a comma separated list, ignoring empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
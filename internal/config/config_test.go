@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	file := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	return file
+}
+
+func TestPrecedence(t *testing.T) {
+	c := Default()
+	file := writeConfigFile(t, "config.yaml", `
+manifest:
+  ttl: 1h
+  http_timeout: 10s
+  mirrors: [https://a.example/manifest.json, https://b.example/manifest.json]
+exclude:
+  apps: [homebrew]
+output: json
+`)
+	assert.NoError(t, c.loadFile(file))
+	assert.NoError(t, c.loadEnv([]string{"DEVCLEANER_MANIFEST_TTL=2h", "DEVCLEANER_OUTPUT=text", "DEVCLEANER_PROXY="}))
+	assert.NoError(t, c.SetFlag("output", "json"))
+
+	assert.Equal(t, file, c.File)
+	assert.Equal(t, 2*time.Hour, c.ManifestTtl)
+	assert.Equal(t, OriginEnv, c.Origin("manifest.ttl"))
+	assert.Equal(t, 10*time.Second, c.HttpTimeout)
+	assert.Equal(t, OriginFile, c.Origin("manifest.http_timeout"))
+	assert.Equal(t, []string{"https://a.example/manifest.json", "https://b.example/manifest.json"}, c.ManifestMirrors)
+	assert.Equal(t, []string{"homebrew"}, c.ExcludedApps)
+	assert.Equal(t, "json", c.Output)
+	assert.Equal(t, OriginFlag, c.Origin("output"))
+	assert.Equal(t, defaultManifestUrl, c.ManifestUrl)
+	assert.Equal(t, OriginDefault, c.Origin("manifest.url"))
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.toml": "safe_mode = true\n[policy]\ninclude_user_data = true\n",
+		"config.json": `{"safe_mode": true, "policy": {"include_user_data": true}}`,
+	} {
+		c := Default()
+		assert.NoError(t, c.loadFile(writeConfigFile(t, name, content)), name)
+		assert.True(t, c.SafeMode, name)
+		assert.True(t, c.IncludeUserData, name)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	c := Default()
+	file := writeConfigFile(t, "config.toml", "bogus = 1\nsafe_mode = [true]\noutput = \"xml\"\n")
+	err := c.loadFile(file)
+	assert.ErrorIs(t, err, ErrUnknownSetting)
+	assert.ErrorContains(t, err, "unknown setting bogus (from file "+file+")")
+	assert.ErrorContains(t, err, `invalid safe_mode "[true]" from file `+file+" (expected a single value")
+	assert.ErrorContains(t, err, `invalid output "xml"`)
+
+	var fileErr *FileError
+	assert.ErrorAs(t, c.loadFile(writeConfigFile(t, "config.ini", "")), &fileErr)
+	assert.ErrorContains(t, fileErr, "unknown config file format")
+	assert.ErrorAs(t, c.loadFile(filepath.Join(t.TempDir(), "missing.toml")), &fileErr)
+	assert.ErrorIs(t, fileErr, os.ErrNotExist)
+}
+
+func TestLoadFrom(t *testing.T) {
+	file := writeConfigFile(t, "config.toml", "log_level = \"DEBUG\"\n")
+	c, err := LoadFrom(file, []string{"DEVCLEANER_SAFE_MODE=1", "HOME=/home/test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG", c.LogLevel)
+	assert.True(t, c.SafeMode)
+	assert.Equal(t, file, c.File)
+
+	c, err = LoadFrom("", nil)
+	assert.NoError(t, err)
+	defaults := Default()
+	assert.Equal(t, defaults.Settings(), c.Settings())
+
+	_, err = LoadFrom("", []string{"DEVCLEANER_HTTP_TIMEOUT=-1s", "DEVCLEANER_SAFE_MODE=maybe"})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "manifest.http_timeout", validationErr.Key)
+	assert.Equal(t, OriginEnv, validationErr.Origin)
+	assert.Equal(t, "DEVCLEANER_HTTP_TIMEOUT", validationErr.Source)
+	assert.ErrorContains(t, err, `invalid safe_mode "maybe" from env DEVCLEANER_SAFE_MODE`)
+
+	_, err = LoadFrom(writeConfigFile(t, "config.toml", "log_level = \"loud\"\n"), nil)
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "log_level", validationErr.Key)
+	assert.Equal(t, OriginFile, validationErr.Origin)
+	_, err = LoadFrom("", []string{"DEVCLEANER_LOGLEVEL=verbose"})
+	assert.ErrorContains(t, err, `invalid log_level "verbose" from env DEVCLEANER_LOGLEVEL`)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownSetting = errors.New("unknown setting")
+
+// An invalid value of a setting.
+type ValidationError struct {
+	Key    string
+	Value  string
+	Origin Origin
+	// Environment variable or config file the value comes from
+	Source string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	from := string(e.Origin)
+	if e.Source != "" {
+		from += " " + e.Source
+	}
+	if errors.Is(e.Err, ErrUnknownSetting) {
+		return fmt.Sprintf("unknown setting %s (from %s)", e.Key, from)
+	}
+	return fmt.Sprintf("invalid %s %q from %s (%s)", e.Key, e.Value, from, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// A config file that cannot be read or parsed.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("invalid config file %s (%s)", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
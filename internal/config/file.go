@@ -0,0 +1,125 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/adrg/xdg"
+	"gopkg.in/yaml.v3"
+)
+
+// Config file names, in lookup order.
+var configFileNames = []string{"config.toml", "config.yaml", "config.yml", "config.json"}
+
+// Returns the config file to load: DEVCLEANER_CONFIG if set, else the
+// first existing config file in $XDG_CONFIG_HOME/devcleaner. Returns an
+// empty string if there is none.
+func configFilePath() string {
+	if name := os.Getenv("DEVCLEANER_CONFIG"); name != "" {
+		return name
+	}
+	dir := path.Join(xdg.ConfigHome, "devcleaner")
+	for _, name := range configFileNames {
+		if _, err := os.Stat(path.Join(dir, name)); err == nil {
+			return path.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// Loads the settings of a config file, written in TOML, YAML or JSON
+// depending on its extension.
+func (c *RuntimeConfig) loadFile(name string) error {
+	if name == "" {
+		return nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return &FileError{Path: name, Err: err}
+	}
+	var doc map[string]any
+	switch ext := filepath.Ext(name); ext {
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	default:
+		return &FileError{Path: name, Err: fmt.Errorf("unknown config file format %s", ext)}
+	}
+	if err != nil {
+		return &FileError{Path: name, Err: err}
+	}
+
+	values := map[string]any{}
+	flatten("", doc, values)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	// report errors in a stable order
+	sort.Strings(keys)
+	var errs []error
+	for _, key := range keys {
+		s, err := lookupSetting(key)
+		if err != nil {
+			errs = append(errs, &ValidationError{Key: key, Value: fmt.Sprint(values[key]), Origin: OriginFile, Source: name, Err: err})
+			continue
+		}
+		value, err := fileValue(s, values[key])
+		if err != nil {
+			errs = append(errs, &ValidationError{Key: key, Value: fmt.Sprint(values[key]), Origin: OriginFile, Source: name, Err: err})
+			continue
+		}
+		if err := c.set(s, value, OriginFile, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	c.File = name
+	return nil
+}
+
+// Flattens nested tables into dotted keys.
+func flatten(prefix string, doc map[string]any, values map[string]any) {
+	for key, value := range doc {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if table, ok := value.(map[string]any); ok {
+			flatten(key, table, values)
+		} else {
+			values[key] = value
+		}
+	}
+}
+
+// Converts a value of the config file to the string form of the setting.
+func fileValue(s *setting, value any) (string, error) {
+	items, isList := value.([]any)
+	switch {
+	case isList && !s.list:
+		return "", errors.New("expected a single value, not a list")
+	case isList:
+		strs := make([]string, len(items))
+		for i, item := range items {
+			strs[i] = fmt.Sprint(item)
+			if strings.Contains(strs[i], ",") {
+				return "", errors.New("items cannot contain commas")
+			}
+		}
+		return strings.Join(strs, ","), nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
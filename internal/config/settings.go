@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/disk"
+)
+
+// A setting that can be set in the config file, with an environment
+// variable or with a flag. Values are parsed from strings, lists being
+// comma separated.
+type setting struct {
+	// Key in the config file, nested tables are separated by dots
+	key  string
+	env  string
+	list bool
+	set  func(c *RuntimeConfig, value string) error
+	get  func(c *RuntimeConfig) string
+}
+
+var settings = []setting{
+	{
+		key: "manifest.url", env: "DEVCLEANER_MANIFEST_URL",
+		set: func(c *RuntimeConfig, v string) error { c.ManifestUrl = v; return nil },
+		get: func(c *RuntimeConfig) string { return c.ManifestUrl },
+	},
+	{
+		key: "manifest.mirrors", env: "DEVCLEANER_MANIFEST_MIRRORS", list: true,
+		set: func(c *RuntimeConfig, v string) error { c.ManifestMirrors = splitList(v); return nil },
+		get: func(c *RuntimeConfig) string { return strings.Join(c.ManifestMirrors, ",") },
+	},
+	{
+		key: "manifest.ttl", env: "DEVCLEANER_MANIFEST_TTL",
+		set: func(c *RuntimeConfig, v string) error { return parseDuration(v, &c.ManifestTtl, false) },
+		get: func(c *RuntimeConfig) string { return c.ManifestTtl.String() },
+	},
+	{
+		key: "manifest.ca_bundle", env: "DEVCLEANER_CA_BUNDLE",
+		set: func(c *RuntimeConfig, v string) error { c.CABundle = v; return nil },
+		get: func(c *RuntimeConfig) string { return c.CABundle },
+	},
+	{
+		key: "manifest.proxy", env: "DEVCLEANER_PROXY",
+		set: func(c *RuntimeConfig, v string) error {
+			if _, err := url.Parse(v); err != nil {
+				return err
+			}
+			c.Proxy = v
+			return nil
+		},
+		get: func(c *RuntimeConfig) string { return c.Proxy },
+	},
+	{
+		key: "manifest.http_timeout", env: "DEVCLEANER_HTTP_TIMEOUT",
+		set: func(c *RuntimeConfig, v string) error { return parseDuration(v, &c.HttpTimeout, true) },
+		get: func(c *RuntimeConfig) string { return c.HttpTimeout.String() },
+	},
+	{
+		key: "log_level", env: "DEVCLEANER_LOGLEVEL",
+		set: func(c *RuntimeConfig, v string) error {
+			// the levels of log.ParseLevel, which cannot be imported here
+			switch strings.ToLower(v) {
+			case "debug", "info", "warn", "error", "fatal":
+				c.LogLevel = v
+				return nil
+			default:
+				return fmt.Errorf("expected debug, info, warn, error or fatal")
+			}
+		},
+		get: func(c *RuntimeConfig) string { return c.LogLevel },
+	},
+	{
+		key: "safe_mode", env: "DEVCLEANER_SAFE_MODE",
+		set: func(c *RuntimeConfig, v string) error { return parseBool(v, &c.SafeMode) },
+		get: func(c *RuntimeConfig) string { return strconv.FormatBool(c.SafeMode) },
+	},
+	{
+		key: "command_timeout", env: "DEVCLEANER_COMMAND_TIMEOUT",
+		set: func(c *RuntimeConfig, v string) error { return parseDuration(v, &c.CommandTimeout, true) },
+		get: func(c *RuntimeConfig) string { return c.CommandTimeout.String() },
+	},
+	{
+		key: "allowed_commands", env: "DEVCLEANER_ALLOWED_COMMANDS", list: true,
+		set: func(c *RuntimeConfig, v string) error { c.AllowedCommands = splitList(v); return nil },
+		get: func(c *RuntimeConfig) string { return strings.Join(c.AllowedCommands, ",") },
+	},
+	{
+		key: "exclude.apps", env: "DEVCLEANER_EXCLUDE_APPS", list: true,
+		set: func(c *RuntimeConfig, v string) error { c.ExcludedApps = splitList(v); return nil },
+		get: func(c *RuntimeConfig) string { return strings.Join(c.ExcludedApps, ",") },
+	},
+	{
+		key: "exclude.categories", env: "DEVCLEANER_EXCLUDE_CATEGORIES", list: true,
+		set: func(c *RuntimeConfig, v string) error { c.ExcludedCategories = splitList(v); return nil },
+		get: func(c *RuntimeConfig) string { return strings.Join(c.ExcludedCategories, ",") },
+	},
+	{
+		key: "exclude.paths", env: "DEVCLEANER_EXCLUDE_PATHS", list: true,
+		set: func(c *RuntimeConfig, v string) error { c.ExcludedPaths = splitList(v); return nil },
+		get: func(c *RuntimeConfig) string { return strings.Join(c.ExcludedPaths, ",") },
+	},
+	{
+		key: "protected_paths", env: "DEVCLEANER_PROTECTED_PATHS", list: true,
+		set: func(c *RuntimeConfig, v string) error { c.ProtectedPaths = splitList(v); return nil },
+		get: func(c *RuntimeConfig) string { return strings.Join(c.ProtectedPaths, ",") },
+	},
+	{
+		key: "allowed_paths", env: "DEVCLEANER_ALLOWED_PATHS", list: true,
+		set: func(c *RuntimeConfig, v string) error { c.AllowedPaths = splitList(v); return nil },
+		get: func(c *RuntimeConfig) string { return strings.Join(c.AllowedPaths, ",") },
+	},
+	{
+		key: "scan.roots", env: "DEVCLEANER_SCAN_ROOTS", list: true,
+		set: func(c *RuntimeConfig, v string) error { c.ScanRoots = splitList(v); return nil },
+		get: func(c *RuntimeConfig) string { return strings.Join(c.ScanRoots, ",") },
+	},
+	{
+		key: "policy.include_user_data", env: "DEVCLEANER_INCLUDE_USER_DATA",
+		set: func(c *RuntimeConfig, v string) error { return parseBool(v, &c.IncludeUserData) },
+		get: func(c *RuntimeConfig) string { return strconv.FormatBool(c.IncludeUserData) },
+	},
+	{
+		key: "policy.include_unknown_risk", env: "DEVCLEANER_INCLUDE_UNKNOWN_RISK",
+		set: func(c *RuntimeConfig, v string) error { return parseBool(v, &c.IncludeUnknownRisk) },
+		get: func(c *RuntimeConfig) string { return strconv.FormatBool(c.IncludeUnknownRisk) },
+	},
+	{
+		key: "output", env: "DEVCLEANER_OUTPUT",
+		set: func(c *RuntimeConfig, v string) error {
+			if v != "text" && v != "json" {
+				return fmt.Errorf("expected text or json")
+			}
+			c.Output = v
+			return nil
+		},
+		get: func(c *RuntimeConfig) string { return c.Output },
+	},
+	{
+		key: "schedule.max_load", env: "DEVCLEANER_SCHEDULE_MAX_LOAD",
+		set: func(c *RuntimeConfig, v string) error {
+			load, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return err
+			}
+			if load <= 0 {
+				return fmt.Errorf("must be positive")
+			}
+			c.ScheduleMaxLoad = load
+			return nil
+		},
+		get: func(c *RuntimeConfig) string { return strconv.FormatFloat(c.ScheduleMaxLoad, 'g', -1, 64) },
+	},
+	{
+		key: "schedule.on_battery", env: "DEVCLEANER_SCHEDULE_ON_BATTERY",
+		set: func(c *RuntimeConfig, v string) error { return parseBool(v, &c.ScheduleOnBattery) },
+		get: func(c *RuntimeConfig) string { return strconv.FormatBool(c.ScheduleOnBattery) },
+	},
+	{
+		key: "watch.mounts", env: "DEVCLEANER_WATCH_MOUNTS", list: true,
+		set: func(c *RuntimeConfig, v string) error { c.WatchMounts = splitList(v); return nil },
+		get: func(c *RuntimeConfig) string { return strings.Join(c.WatchMounts, ",") },
+	},
+	{
+		key: "watch.min_free", env: "DEVCLEANER_WATCH_MIN_FREE",
+		set: func(c *RuntimeConfig, v string) error { return parseThreshold(v, &c.WatchMinFree) },
+		get: func(c *RuntimeConfig) string { return c.WatchMinFree.String() },
+	},
+	{
+		key: "watch.target_free", env: "DEVCLEANER_WATCH_TARGET_FREE",
+		set: func(c *RuntimeConfig, v string) error { return parseThreshold(v, &c.WatchTargetFree) },
+		get: func(c *RuntimeConfig) string { return c.WatchTargetFree.String() },
+	},
+	{
+		key: "watch.interval", env: "DEVCLEANER_WATCH_INTERVAL",
+		set: func(c *RuntimeConfig, v string) error { return parseDuration(v, &c.WatchInterval, true) },
+		get: func(c *RuntimeConfig) string { return c.WatchInterval.String() },
+	},
+	{
+		key: "watch.cooldown", env: "DEVCLEANER_WATCH_COOLDOWN",
+		set: func(c *RuntimeConfig, v string) error { return parseDuration(v, &c.WatchCooldown, false) },
+		get: func(c *RuntimeConfig) string { return c.WatchCooldown.String() },
+	},
+	{
+		key: "watch.event_log", env: "DEVCLEANER_WATCH_EVENT_LOG",
+		set: func(c *RuntimeConfig, v string) error { c.WatchEventLog = v; return nil },
+		get: func(c *RuntimeConfig) string { return c.WatchEventLog },
+	},
+	{
+		key: "audit.log", env: "DEVCLEANER_AUDIT_LOG",
+		set: func(c *RuntimeConfig, v string) error { c.AuditLog = v; return nil },
+		get: func(c *RuntimeConfig) string { return c.AuditLog },
+	},
+}
+
+func lookupSetting(key string) (*setting, error) {
+	for i := range settings {
+		if settings[i].key == key {
+			return &settings[i], nil
+		}
+	}
+	return nil, ErrUnknownSetting
+}
+
+func parseDuration(v string, d *time.Duration, positive bool) error {
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	if positive && parsed <= 0 {
+		return fmt.Errorf("must be positive")
+	}
+	*d = parsed
+	return nil
+}
+
+func parseThreshold(v string, t *disk.Threshold) error {
+	parsed, err := disk.ParseThreshold(v)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+func parseBool(v string, b *bool) error {
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
+
+// The effective value of a setting, see RuntimeConfig.Settings.
+type Setting struct {
+	Key    string
+	Env    string
+	Value  string
+	Origin Origin
+}
+
+// Returns every setting with its effective value and origin.
+func (c *RuntimeConfig) Settings() []Setting {
+	result := make([]Setting, len(settings))
+	for i, s := range settings {
+		result[i] = Setting{Key: s.key, Env: s.env, Value: s.get(c), Origin: c.Origin(s.key)}
+	}
+	return result
+}
@@ -2,6 +2,7 @@ package ansi
 
 import (
 	"fmt"
+	"os"
 	"strings"
 )
 
@@ -79,7 +80,13 @@ func (s Str) Suffix(colors ...Code) Str {
 	return s
 }
 
+// Whether Style adds escape sequences, see https://no-color.org.
+var Enabled = os.Getenv("NO_COLOR") == ""
+
 func (s Str) Style(codes ...Code) Str {
+	if !Enabled {
+		return s
+	}
 	var b strings.Builder
 	for _, c := range codes {
 		b.WriteString(c.String())
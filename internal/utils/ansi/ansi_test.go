@@ -30,3 +30,16 @@ func TestAnsiString(t *testing.T) {
 		Str("Hello World").Style(Red, Bold),
 	)
 }
+
+func TestAnsiStringDisabled(t *testing.T) {
+	Enabled = false
+	t.Cleanup(func() { Enabled = true })
+	assert.Equal(t, Str("Hello World"), Str("Hello World").Style(Red, Bold))
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, Str(""), Sparkline(nil))
+	assert.Equal(t, Str("▁▁▁"), Sparkline([]int64{5, 5, 5}))
+	assert.Equal(t, Str("▁▂▃▄▅▆▇█"), Sparkline([]int64{0, 1, 2, 3, 4, 5, 6, 7}))
+	assert.Equal(t, Str("█▁▄"), Sparkline([]int64{200, 100, 150}))
+}
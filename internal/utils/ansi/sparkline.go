@@ -0,0 +1,27 @@
+package ansi
+
+import "strings"
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Returns a sparkline of the values, one block per value, scaled between the
+// smallest and the largest value.
+func Sparkline(values []int64) Str {
+	if len(values) == 0 {
+		return ""
+	}
+	lowest, highest := values[0], values[0]
+	for _, v := range values {
+		lowest = min(lowest, v)
+		highest = max(highest, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if highest > lowest {
+			i = int((v - lowest) * int64(len(sparks)-1) / (highest - lowest))
+		}
+		b.WriteRune(sparks[i])
+	}
+	return Str(b.String())
+}
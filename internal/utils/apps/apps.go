@@ -1,29 +1,196 @@
 package apps
 
-import "github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+)
 
 type App struct {
-	Name   string             `json:"name"`
-	Path   path.PathPattern   `json:"path"`
-	Caches []path.PathPattern `json:"caches"`
+	Name        string           `json:"name"`
+	Path        path.PathPattern `json:"path"`
+	Caches      []Cache          `json:"caches"`
+	Description string           `json:"description,omitempty"`
+	Category    Category         `json:"category,omitempty"`
+	// Default risk of the caches of this app
+	Risk Risk   `json:"risk,omitempty"`
+	Docs string `json:"docs,omitempty"`
+	// Command printing the version of the app, available to the cache
+	// patterns as `{version}`
+	VersionCommand string `json:"version_command,omitempty"`
+	// Extracts the version from the output of VersionCommand, the first
+	// group if any, or the whole match
+	VersionRegex string `json:"version_regex,omitempty"`
+
+	// Whether the app comes from a local manifest fragment
+	local bool
+}
+
+// Whether the app comes from a manifest fragment of the user rather than
+// from the remote manifest, and may run any command.
+func (a *App) Local() bool {
+	return a.local
+}
+
+// A cache of an app. In manifests a cache without metadata can be written as
+// its path pattern alone.
+type Cache struct {
+	Path        path.PathPattern `json:"path"`
+	Description string           `json:"description,omitempty"`
+	Category    Category         `json:"category,omitempty"`
+	Risk        Risk             `json:"risk,omitempty"`
+	Docs        string           `json:"docs,omitempty"`
+}
+
+func (c *Cache) UnmarshalJSON(data []byte) error {
+	var pattern path.PathPattern
+	if err := json.Unmarshal(data, &pattern); err == nil {
+		*c = Cache{Path: pattern}
+		return nil
+	}
+	// avoid recursing into this method
+	type cache Cache
+	return json.Unmarshal(data, (*cache)(c))
+}
+
+func (c Cache) MarshalJSON() ([]byte, error) {
+	if c.Description == "" && c.Category == "" && c.Risk == "" && c.Docs == "" {
+		return json.Marshal(c.Path)
+	}
+	type cache Cache
+	return json.Marshal(cache(c))
+}
+
+func (c Cache) String() string {
+	return string(c.Path)
+}
+
+// Returns the risk of deleting cache, falling back to the risk of the app.
+func (a *App) CacheRisk(cache Cache) Risk {
+	if cache.Risk != "" {
+		return cache.Risk
+	}
+	return a.Risk
+}
+
+// Returns the docs of cache, falling back to the docs of the app.
+func (a *App) CacheDocs(cache Cache) string {
+	if cache.Docs != "" {
+		return cache.Docs
+	}
+	return a.Docs
+}
+
+var defaultVersionRegex = regexp.MustCompile(`\d+(?:\.\d+)+(?:-[0-9A-Za-z.]+)?`)
+
+// Runs the version command of the app and extracts its version. Returns an
+// empty version if the app has no version command.
+func (a *App) DetectVersion(ctx *path.PathContext) (string, error) {
+	if a.VersionCommand == "" {
+		return "", nil
+	}
+	re := defaultVersionRegex
+	if a.VersionRegex != "" {
+		var err error
+		if re, err = regexp.Compile(a.VersionRegex); err != nil {
+			return "", fmt.Errorf("invalid version regex of %s (%s)", a.Name, err)
+		}
+	}
+	lines, err := ctx.RunCommand(a.VersionCommand)
+	if err != nil {
+		return "", err
+	}
+	match := re.FindStringSubmatch(strings.Join(lines, "\n"))
+	switch {
+	case match == nil:
+		return "", fmt.Errorf("no version found in the output of %s", a.VersionCommand)
+	case len(match) > 1:
+		return match[1], nil
+	default:
+		return match[0], nil
+	}
+}
+
+type Category string
+
+const (
+	CategoryPackageManager = Category("package_manager")
+	CategoryIDE            = Category("ide")
+	CategoryContainer      = Category("container")
+	CategorySDK            = Category("sdk")
+)
+
+func (c Category) String() string {
+	switch c {
+	case CategoryPackageManager:
+		return "package manager"
+	case CategoryIDE:
+		return "IDE"
+	case CategoryContainer:
+		return "container"
+	case CategorySDK:
+		return "SDK"
+	default:
+		// categories added by newer manifests are shown as is
+		return string(c)
+	}
+}
+
+// How much it costs to delete a cache.
+type Risk string
+
+const (
+	// The cache is regenerated transparently when needed
+	RiskSafe = Risk("safe")
+	// The cache is regenerated but it takes a while, e.g. large downloads or builds
+	RiskSlowRebuild = Risk("slow_rebuild")
+	// The cache contains data that cannot be regenerated
+	RiskUserData = Risk("user_data")
+)
+
+// Whether the risk is one of the risks above. Caches with no risk, or a risk
+// added by a newer manifest, may cost anything to delete.
+func (r Risk) Known() bool {
+	return r == RiskSafe || r == RiskSlowRebuild || r == RiskUserData
+}
+
+func (r Risk) String() string {
+	switch r {
+	case RiskSafe:
+		return "safe, regenerated when needed"
+	case RiskSlowRebuild:
+		return "slow to rebuild"
+	case RiskUserData:
+		return "contains user data"
+	case "":
+		return "unknown risk"
+	default:
+		return string(r)
+	}
 }
 
 var knownApps = []App{
 	// homebrew
 	{
-		Name:   "homebrew",
-		Path:   "{os==darwin:/opt/homebrew/bin/brew,linux:/home/linuxbrew/.linuxbrew/bin/brew}",
-		Caches: []path.PathPattern{"{os==darwin:/opt/homebrew/Cellar,linux:/home/linuxbrew/.linuxbrew/Cellar}"},
+		Name:     "homebrew",
+		Path:     "{os==darwin:/opt/homebrew/bin/brew,linux:/home/linuxbrew/.linuxbrew/bin/brew}",
+		Caches:   []Cache{{Path: "{os==darwin:/opt/homebrew/Cellar,linux:/home/linuxbrew/.linuxbrew/Cellar}"}},
+		Category: CategoryPackageManager,
 	},
 	// npm
 	{
-		Name:   "npm",
-		Path:   "{os==darwin:/usr/local/bin/npm,linux:/usr/bin/npm}",
-		Caches: []path.PathPattern{"{os==darwin:/usr/local/lib/node_modules,linux:/usr/lib/node_modules}"},
+		Name:     "npm",
+		Path:     "{os==darwin:/usr/local/bin/npm,linux:/usr/bin/npm}",
+		Caches:   []Cache{{Path: "{os==darwin:/usr/local/lib/node_modules,linux:/usr/lib/node_modules}"}},
+		Category: CategoryPackageManager,
 	},
 	// yarn
 	{
-		Name:   "yarn",
-		Path:   "{os==darwin:/usr/local/bin/yarn,linux:/usr/bin/yarn}",
-		Caches: []path.PathPattern{"{os==darwin:/usr/local/lib/node_modules,linux:/usr/lib/node_modules}"},
+		Name:     "yarn",
+		Path:     "{os==darwin:/usr/local/bin/yarn,linux:/usr/bin/yarn}",
+		Caches:   []Cache{{Path: "{os==darwin:/usr/local/lib/node_modules,linux:/usr/lib/node_modules}"}},
+		Category: CategoryPackageManager,
 	}}
@@ -0,0 +1,62 @@
+package apps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheShorthand(t *testing.T) {
+	var caches []Cache
+	err := json.Unmarshal([]byte(`["{env.HOME}/.cargo/registry",{"path":"{env.HOME}/.cargo/git","risk":"slow_rebuild","description":"Git dependencies"}]`), &caches)
+	assert.NoError(t, err)
+	assert.Equal(t, []Cache{
+		{Path: "{env.HOME}/.cargo/registry"},
+		{Path: "{env.HOME}/.cargo/git", Risk: RiskSlowRebuild, Description: "Git dependencies"},
+	}, caches)
+
+	data, err := json.Marshal(caches)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `["{env.HOME}/.cargo/registry",{"path":"{env.HOME}/.cargo/git","risk":"slow_rebuild","description":"Git dependencies"}]`, string(data))
+}
+
+func TestCacheRiskFallsBackToApp(t *testing.T) {
+	app := App{Name: "docker", Risk: RiskUserData, Docs: "https://docs.docker.com"}
+	assert.Equal(t, RiskUserData, app.CacheRisk(Cache{}))
+	assert.Equal(t, RiskSafe, app.CacheRisk(Cache{Risk: RiskSafe}))
+	assert.Equal(t, "https://docs.docker.com", app.CacheDocs(Cache{}))
+}
+
+func TestDetectVersion(t *testing.T) {
+	ctx := path.NewPathContext()
+	ctx.SetCommandRunner(func(_ context.Context, name string, args ...string) ([]byte, error) {
+		if command := strings.Join(append([]string{name}, args...), " "); command != "go version" {
+			return nil, fmt.Errorf("unexpected command %s", command)
+		}
+		return []byte("go version go1.23.1 linux/amd64\n"), nil
+	})
+
+	version, err := (&App{Name: "go"}).DetectVersion(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, version)
+
+	version, err = (&App{Name: "go", VersionCommand: "go version", VersionRegex: `go(\d+\.\d+)`}).DetectVersion(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.23", version)
+
+	version, err = (&App{Name: "go", VersionCommand: "go version"}).DetectVersion(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.23.1", version)
+
+	_, err = (&App{Name: "go", VersionCommand: "go version", VersionRegex: `(`}).DetectVersion(ctx)
+	assert.ErrorContains(t, err, "invalid version regex")
+
+	ctx.SetSafeMode(true)
+	_, err = (&App{Name: "go", VersionCommand: "go version"}).DetectVersion(ctx)
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package apps
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+)
+
+// Remote manifests larger than this are refused.
+const maxManifestSize = 16 << 20
+
+// Fetches the manifest from config.Runtime.ManifestUrl, falling back to the
+// mirrors in order. Sources can be http(s):// or file:// URLs.
+func FetchManifestFromRemote(l *log.Logger) (*Manifest, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	sources := append([]string{config.Runtime.ManifestUrl}, config.Runtime.ManifestMirrors...)
+	var errs []error
+	for _, source := range sources {
+		l.Debug("Fetching manifest from %s", source)
+		manifest, err := fetchManifest(client, source)
+		if err == nil {
+			return manifest, nil
+		}
+		l.Debug("Error fetching manifest from %s: %s", source, err)
+		errs = append(errs, fmt.Errorf("%s: %w", source, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+func fetchManifest(client *http.Client, source string) (*Manifest, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return ReadManifestFile(fileURLPath(u))
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported manifest url scheme %q", u.Scheme)
+	}
+
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxManifestSize {
+		return nil, fmt.Errorf("manifest is larger than %d bytes", maxManifestSize)
+	}
+	// mirrors may serve the other formats, JSON is assumed otherwise
+	format, err := FormatFromPath(u.Path)
+	if err != nil {
+		format = FormatJSON
+	}
+	manifest, _, err := DecodeManifestAs(body, format)
+	return manifest, err
+}
+
+// Returns the local path of a file:// URL, e.g. /C:/manifest.json is
+// C:\manifest.json on windows.
+func fileURLPath(u *url.URL) string {
+	p := u.Path
+	if u.Host != "" && u.Host != "localhost" {
+		// UNC path
+		p = "//" + u.Host + p
+	}
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+func newHTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Runtime.Proxy != "" {
+		proxy, err := url.Parse(config.Runtime.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %s (%s)", config.Runtime.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if config.Runtime.CABundle != "" {
+		pool, err := loadCABundle(config.Runtime.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: transport, Timeout: config.Runtime.HttpTimeout}, nil
+}
+
+// Returns the system certificate pool with the certificates of bundle added.
+func loadCABundle(bundle string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA bundle (%s)", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", bundle)
+	}
+	return pool, nil
+}
@@ -0,0 +1,70 @@
+package apps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func withRuntimeConfig(t *testing.T, c config.RuntimeConfig) {
+	previous := config.Runtime
+	config.Runtime = c
+	t.Cleanup(func() { config.Runtime = previous })
+}
+
+func TestFetchFallsBackToMirrors(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer broken.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"apps":[{"name":"cargo","path":"/bin/cargo","caches":[]}],"version":2}`))
+	}))
+	defer mirror.Close()
+
+	withRuntimeConfig(t, config.RuntimeConfig{
+		ManifestUrl:     broken.URL + "/manifest.json",
+		ManifestMirrors: []string{mirror.URL + "/manifest.json"},
+		HttpTimeout:     time.Second,
+	})
+	manifest, err := FetchManifestFromRemote(log.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "cargo", manifest.Apps[0].Name)
+}
+
+func TestFetchTimesOut(t *testing.T) {
+	done := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hanging.Close()
+	defer close(done)
+
+	withRuntimeConfig(t, config.RuntimeConfig{
+		ManifestUrl: hanging.URL,
+		HttpTimeout: 50 * time.Millisecond,
+	})
+	_, err := FetchManifestFromRemote(log.New())
+	assert.Error(t, err)
+}
+
+func TestFetchFileURL(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "manifest.yaml")
+	assert.NoError(t, os.WriteFile(name, []byte(yamlManifest), 0644))
+
+	withRuntimeConfig(t, config.RuntimeConfig{
+		ManifestUrl: (&url.URL{Scheme: "file", Path: "/" + strings.TrimPrefix(filepath.ToSlash(name), "/")}).String(),
+	})
+	manifest, err := FetchManifestFromRemote(log.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "cargo", manifest.Apps[0].Name)
+}
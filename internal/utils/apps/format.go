@@ -0,0 +1,129 @@
+package apps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// The file formats a manifest can be written in.
+// Whatever the format, manifests are decoded into the same Manifest type,
+// going through a JSON document so that migrations and custom unmarshalers
+// only have to be written once.
+type ManifestFormat string
+
+const (
+	FormatJSON ManifestFormat = "json"
+	FormatYAML ManifestFormat = "yaml"
+	FormatTOML ManifestFormat = "toml"
+)
+
+// File extensions recognized as manifests, in lookup order.
+var ManifestExtensions = []string{".json", ".yaml", ".yml", ".toml"}
+
+func ParseManifestFormat(s string) (ManifestFormat, error) {
+	switch strings.ToLower(s) {
+	case "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "toml":
+		return FormatTOML, nil
+	default:
+		return "", fmt.Errorf("unknown manifest format %s", s)
+	}
+}
+
+// Detects the format of a manifest file from its extension.
+func FormatFromPath(name string) (ManifestFormat, error) {
+	ext := filepath.Ext(name)
+	if ext == "" {
+		return "", fmt.Errorf("cannot detect the manifest format of %s", name)
+	}
+	return ParseManifestFormat(ext[1:])
+}
+
+func (f ManifestFormat) decodeDocument(data []byte) (map[string]any, error) {
+	var doc map[string]any
+	var err error
+	switch f {
+	case FormatJSON:
+		err = json.Unmarshal(data, &doc)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &doc)
+	case FormatTOML:
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unknown manifest format %s", f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("manifest is empty")
+	}
+	return doc, nil
+}
+
+func (f ManifestFormat) encodeDocument(doc map[string]any) ([]byte, error) {
+	switch f {
+	case FormatYAML:
+		return yaml.Marshal(doc)
+	case FormatTOML:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown manifest format %s", f)
+	}
+}
+
+// Encodes a manifest in the given format.
+func EncodeManifest(manifest *Manifest, format ManifestFormat) ([]byte, error) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatJSON {
+		return append(data, '\n'), nil
+	}
+	// round-trip through a generic document so that the other formats use
+	// the same field names and shapes as JSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return format.encodeDocument(normalizeNumbers(doc).(map[string]any))
+}
+
+// Replaces json.Number values with int64 or float64 so that they are not
+// encoded as strings.
+func normalizeNumbers(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = normalizeNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = normalizeNumbers(e)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return value
+}
@@ -0,0 +1,68 @@
+package apps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlManifest = `# caches of our internal tools
+version: 2
+apps:
+  - name: cargo
+    path: "{env.HOME}/.cargo/bin/cargo"
+    caches:
+      - "{env.HOME}/.cargo/registry" # downloaded crates
+`
+
+const tomlManifest = `# caches of our internal tools
+version = 2
+
+[[apps]]
+name = "cargo"
+path = "{env.HOME}/.cargo/bin/cargo"
+caches = ["{env.HOME}/.cargo/registry"] # downloaded crates
+`
+
+func TestDecodeYAMLManifest(t *testing.T) {
+	manifest, migrated, err := DecodeManifestAs([]byte(yamlManifest), FormatYAML)
+	assert.NoError(t, err)
+	assert.False(t, migrated)
+	assert.Equal(t, "cargo", manifest.Apps[0].Name)
+	assert.Equal(t, "{env.HOME}/.cargo/registry", string(manifest.Apps[0].Caches[0].Path))
+}
+
+func TestDecodeTOMLManifest(t *testing.T) {
+	manifest, migrated, err := DecodeManifestAs([]byte(tomlManifest), FormatTOML)
+	assert.NoError(t, err)
+	assert.False(t, migrated)
+	assert.Equal(t, "cargo", manifest.Apps[0].Name)
+	assert.Equal(t, "{env.HOME}/.cargo/registry", string(manifest.Apps[0].Caches[0].Path))
+}
+
+func TestManifestFormatsRoundTrip(t *testing.T) {
+	manifest, _, err := DecodeManifestAs([]byte(yamlManifest), FormatYAML)
+	assert.NoError(t, err)
+	for _, format := range []ManifestFormat{FormatJSON, FormatYAML, FormatTOML} {
+		data, err := EncodeManifest(manifest, format)
+		assert.NoError(t, err)
+		decoded, _, err := DecodeManifestAs(data, format)
+		assert.NoError(t, err)
+		assert.Equal(t, manifest, decoded, "round trip through %s", format)
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	for name, expected := range map[string]ManifestFormat{
+		"manifest.json": FormatJSON,
+		"tools.yml":     FormatYAML,
+		"tools.yaml":    FormatYAML,
+		"tools.toml":    FormatTOML,
+	} {
+		format, err := FormatFromPath(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, format)
+	}
+	_, err := FormatFromPath("manifest")
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+package apps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+)
+
+// Reads a manifest file, detecting its format from its extension.
+func ReadManifestFile(name string) (*Manifest, error) {
+	format, err := FormatFromPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	manifest, _, err := DecodeManifestAs(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s (%s)", name, err)
+	}
+	return manifest, nil
+}
+
+// Loads the manifest fragments from config.GetManifestFragmentsDir(),
+// ordered by file name. Files that are not manifests are ignored.
+func LoadManifestFragments(l *log.Logger) ([]*Manifest, error) {
+	dir := config.GetManifestFragmentsDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var fragments []*Manifest
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(ManifestExtensions, filepath.Ext(entry.Name())) {
+			continue
+		}
+		name := filepath.Join(dir, entry.Name())
+		l.Debug("Loading manifest fragment %s", name)
+		fragment, err := ReadManifestFile(name)
+		if err != nil {
+			return nil, err
+		}
+		for i := range fragment.Apps {
+			fragment.Apps[i].local = true
+		}
+		fragments = append(fragments, fragment)
+	}
+	return fragments, nil
+}
+
+// Adds apps to the manifest, replacing the apps with the same name.
+func (m *Manifest) MergeApps(apps []App) {
+	for _, app := range apps {
+		if i := slices.IndexFunc(m.Apps, func(a App) bool { return a.Name == app.Name }); i >= 0 {
+			m.Apps[i] = app
+		} else {
+			m.Apps = append(m.Apps, app)
+		}
+	}
+}
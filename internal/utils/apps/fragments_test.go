@@ -0,0 +1,30 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnlyFragmentAppsAreLocal(t *testing.T) {
+	configHome := xdg.ConfigHome
+	xdg.ConfigHome = t.TempDir()
+	t.Cleanup(func() { xdg.ConfigHome = configHome })
+	dir := config.GetManifestFragmentsDir()
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "tools.json"), []byte(`{"apps":[{"name":"tool","path":"{cmd:which tool}","caches":[]}],"version":1}`), 0644))
+
+	fragments, err := LoadManifestFragments(log.New())
+	assert.NoError(t, err)
+	assert.Len(t, fragments, 1)
+	manifest := &Manifest{Apps: []App{{Name: "remote"}}}
+	manifest.MergeApps(fragments[0].Apps)
+	assert.Len(t, manifest.Apps, 2)
+	assert.False(t, manifest.Apps[0].Local(), manifest.Apps[0].Name)
+	assert.True(t, manifest.Apps[1].Local(), manifest.Apps[1].Name)
+}
@@ -1,15 +1,15 @@
 package apps
 
 import (
-	"encoding/json"
 	"errors"
-	"io"
-	"net/http"
+	"fmt"
 	"os"
-	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	dio "github.com/gaetschwartz/devcleaner-go/internal/utils/io"
 	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
 )
 
@@ -22,10 +22,44 @@ type Manifest struct {
 type ManifestWithTime struct {
 	Manifest Manifest
 	ModTime  time.Time
+	// Whether the manifest was migrated from an older version when read
+	Migrated bool
+}
+
+// The local manifest exists but could not be decoded, e.g. because it was
+// truncated by an interrupted write.
+type CorruptManifestError struct {
+	Path string
+	Err  error
+}
+
+func (e *CorruptManifestError) Error() string {
+	return fmt.Sprintf("local manifest %s is corrupt (%s)", e.Path, e.Err)
+}
+
+func (e *CorruptManifestError) Unwrap() error {
+	return e.Err
+}
+
+// Returns the path of the local manifest. It is usually JSON, but may have
+// been converted to any of the ManifestExtensions by the user.
+func localManifestPath() string {
+	defaultPath := config.GetLocalManifestPath()
+	stem := strings.TrimSuffix(defaultPath, filepath.Ext(defaultPath))
+	for _, ext := range ManifestExtensions {
+		if _, err := os.Stat(stem + ext); err == nil {
+			return stem + ext
+		}
+	}
+	return defaultPath
 }
 
 func GetLocalManifest() (*ManifestWithTime, error) {
-	manifestPath := config.GetLocalManifestPath()
+	manifestPath := localManifestPath()
+	format, err := FormatFromPath(manifestPath)
+	if err != nil {
+		return nil, err
+	}
 	// check if file exists
 	stat, err := os.Stat(manifestPath)
 	if err != nil {
@@ -36,68 +70,125 @@ func GetLocalManifest() (*ManifestWithTime, error) {
 	if err != nil {
 		return nil, err
 	}
-	var manifest Manifest
-	err = json.Unmarshal(data, &manifest)
+	manifest, migrated, err := DecodeManifestAs(data, format)
 	if err != nil {
-		return nil, err
+		var unsupported *UnsupportedManifestVersionError
+		if errors.As(err, &unsupported) {
+			return nil, err
+		}
+		return nil, &CorruptManifestError{Path: manifestPath, Err: err}
 	}
-	return &ManifestWithTime{Manifest: manifest, ModTime: stat.ModTime()}, nil
+	return &ManifestWithTime{Manifest: *manifest, ModTime: stat.ModTime(), Migrated: migrated}, nil
 }
 
-func FetchManifestFromRemote() (*Manifest, error) {
-	manifest_url := config.Runtime.ManifestUrl
-	resp, err := http.Get(manifest_url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+func writeToLocalManifest(manifest *Manifest) error {
+	manifestPath := localManifestPath()
+	format, err := FormatFromPath(manifestPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var manifest Manifest
-	err = json.Unmarshal(body, &manifest)
+	data, err := EncodeManifest(manifest, format)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &manifest, nil
+	return dio.WriteFileAtomic(manifestPath, data, 0644)
 }
-func writeToLocalManifest(manifest *Manifest) error {
-	filepath := config.GetLocalManifestPath()
-	_, err := os.Stat(filepath)
+
+// Returns the local manifest if it can be used, possibly once migrated.
+// A missing, outdated, corrupt or too recent local manifest is reported as
+// not usable rather than as an error.
+func getUsableLocalManifest(l *log.Logger) (*ManifestWithTime, bool, error) {
+	var unsupported *UnsupportedManifestVersionError
+	var corrupt *CorruptManifestError
+	localManifest, err := GetLocalManifest()
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			// create parent directories
-			os.MkdirAll(path.Dir(filepath), 0755)
+			l.Debug("No local manifest found, fetching remote manifest")
+		} else if errors.As(err, &unsupported) {
+			// probably written by a newer devcleaner, leave it alone unless
+			// the remote manifest is something we can read
+			l.Warn("Ignoring local manifest: %s", err)
+		} else if errors.As(err, &corrupt) {
+			l.Warn("%s, fetching remote manifest", err)
 		} else {
-			return err
+			return nil, false, err
 		}
+		return nil, false, nil
 	}
-	data, err := json.Marshal(manifest)
-	if err != nil {
-		return err
+	l.Debug("Found local manifest at %s", localManifestPath())
+	// check if its not too old
+	if time.Since(localManifest.ModTime) >= config.Runtime.ManifestTtl {
+		l.Debug("Local manifest is too old, fetching remote manifest")
+		return nil, false, nil
 	}
-	return os.WriteFile(filepath, data, 0644)
+	l.Debug("Local manifest is not too old")
+	return localManifest, true, nil
 }
 
+// Rewrites a migrated local manifest in the current version. The caller must
+// hold the lock of the local manifest.
+func updateMigratedManifest(l *log.Logger, localManifest *ManifestWithTime) {
+	l.Debug("Local manifest was migrated to version %d, updating it", CurrentManifestVersion)
+	if err := writeToLocalManifest(&localManifest.Manifest); err != nil {
+		l.Warn("Error updating local manifest: %s", err)
+		return
+	}
+	// a migration is not a refresh, keep the original age
+	if err := os.Chtimes(localManifestPath(), localManifest.ModTime, localManifest.ModTime); err != nil {
+		l.Warn("Could not keep the age of the local manifest, it will be refreshed later than expected: %s", err)
+	}
+}
+
+// Returns the manifest to use, refreshing the local manifest from the remote
+// if needed, with the local manifest fragments merged in.
 func GetManifest(l *log.Logger) (*Manifest, error) {
-	if localManifest, err := GetLocalManifest(); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			l.Debug("No local manifest found, fetching remote manifest")
-		} else {
-			return nil, err
+	manifest, err := getBaseManifest(l)
+	if err != nil {
+		return nil, err
+	}
+	fragments, err := LoadManifestFragments(l)
+	if err != nil {
+		return nil, err
+	}
+	for _, fragment := range fragments {
+		manifest.MergeApps(fragment.Apps)
+	}
+	return manifest, nil
+}
+
+func getBaseManifest(l *log.Logger) (*Manifest, error) {
+	localManifest, usable, err := getUsableLocalManifest(l)
+	if err != nil {
+		return nil, err
+	} else if usable && !localManifest.Migrated {
+		return &localManifest.Manifest, nil
+	}
+
+	// Only one process refreshes or migrates the local manifest at a time,
+	// the others wait and then pick up the refreshed manifest.
+	lockPath := config.GetLocalManifestPath() + ".lock"
+	if lock, err := dio.LockFile(lockPath); err != nil {
+		if usable {
+			l.Warn("Could not lock %s, using the local manifest without updating it: %s", lockPath, err)
+			return &localManifest.Manifest, nil
 		}
-	} else if localManifest != nil {
-		l.Debug("Found local manifest at %s", config.GetLocalManifestPath())
-		// check if its not too old
-		if time.Since(localManifest.ModTime) < config.Runtime.ManifestTtl {
-			l.Debug("Local manifest is not too old")
+		l.Warn("Could not lock %s, refreshing without lock: %s", lockPath, err)
+	} else {
+		defer lock.Unlock()
+		// the first check already reported why the local manifest is not usable
+		quiet := &log.Logger{CurrentLevel: log.LevelNone}
+		if localManifest, ok, err := getUsableLocalManifest(quiet); err != nil {
+			return nil, err
+		} else if ok && localManifest.Migrated {
+			updateMigratedManifest(l, localManifest)
+			return &localManifest.Manifest, nil
+		} else if ok {
+			l.Debug("Local manifest was refreshed by another process")
 			return &localManifest.Manifest, nil
 		}
-
-		l.Debug("Local manifest is too old, fetching remote manifest")
 	}
-	remoteManifest, err := FetchManifestFromRemote()
+
+	remoteManifest, err := FetchManifestFromRemote(l)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,104 @@
+package apps
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	dio "github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// Points the local manifest to a temporary directory and the remote
+// manifest to a file with the given content.
+func withManifests(t *testing.T, remote string) string {
+	dataHome := xdg.DataHome
+	xdg.DataHome = t.TempDir()
+	t.Cleanup(func() { xdg.DataHome = dataHome })
+
+	name := filepath.Join(t.TempDir(), "remote.yaml")
+	assert.NoError(t, os.WriteFile(name, []byte(remote), 0644))
+	withRuntimeConfig(t, config.RuntimeConfig{
+		ManifestUrl: (&url.URL{Scheme: "file", Path: "/" + strings.TrimPrefix(filepath.ToSlash(name), "/")}).String(),
+		ManifestTtl: time.Hour,
+	})
+	return config.GetLocalManifestPath()
+}
+
+func TestCorruptLocalManifestIsRefetched(t *testing.T) {
+	local := withManifests(t, yamlManifest)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(local), 0755))
+	// as left by an interrupted write
+	assert.NoError(t, os.WriteFile(local, []byte(`{"apps":[{"name":"car`), 0644))
+
+	manifest, err := getBaseManifest(log.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "cargo", manifest.Apps[0].Name)
+
+	refreshed, err := GetLocalManifest()
+	assert.NoError(t, err)
+	assert.Equal(t, "cargo", refreshed.Manifest.Apps[0].Name)
+}
+
+func TestFreshLocalManifestIsUsed(t *testing.T) {
+	local := withManifests(t, yamlManifest)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(local), 0755))
+	assert.NoError(t, os.WriteFile(local, []byte(`{"apps":[{"name":"npm","path":"/bin/npm","caches":[]}],"version":1}`), 0644))
+
+	manifest, err := getBaseManifest(log.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "npm", manifest.Apps[0].Name)
+}
+
+func TestMigratedLocalManifestKeepsItsAge(t *testing.T) {
+	local := withManifests(t, yamlManifest)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(local), 0755))
+	assert.NoError(t, os.WriteFile(local, []byte(`{"apps":[{"name":"npm","path":"/bin/npm","caches":[]}]}`), 0644))
+	age := time.Now().Add(-time.Minute).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(local, age, age))
+
+	manifest, err := getBaseManifest(log.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "npm", manifest.Apps[0].Name)
+
+	migrated, err := GetLocalManifest()
+	assert.NoError(t, err)
+	assert.False(t, migrated.Migrated)
+	assert.True(t, migrated.ModTime.Equal(age))
+}
+
+func TestMigrationWaitsForTheLock(t *testing.T) {
+	local := withManifests(t, yamlManifest)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(local), 0755))
+	v1 := `{"apps":[{"name":"npm","path":"/bin/npm","caches":[]}]}`
+	assert.NoError(t, os.WriteFile(local, []byte(v1), 0644))
+	// another process is refreshing the local manifest
+	lock, err := dio.LockFile(local + ".lock")
+	assert.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		_, err := getBaseManifest(log.New())
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	data, err := os.ReadFile(local)
+	assert.NoError(t, err)
+	assert.Equal(t, v1, string(data), "the local manifest was rewritten without the lock")
+	assert.NoError(t, lock.Unlock())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the manifest was not read after the lock was released")
+	}
+	migrated, err := GetLocalManifest()
+	assert.NoError(t, err)
+	assert.False(t, migrated.Migrated)
+}
@@ -0,0 +1,194 @@
+package apps
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Versions of the manifest format understood by this binary.
+// Manifests older than CurrentManifestVersion are migrated in memory,
+// manifests newer than it are refused.
+const (
+	MinManifestVersion     = 0
+	CurrentManifestVersion = 2
+)
+
+type UnsupportedManifestVersionError struct {
+	Version int
+}
+
+func (e *UnsupportedManifestVersionError) Error() string {
+	if e.Version > CurrentManifestVersion {
+		return fmt.Sprintf("manifest version %d is newer than the latest supported version %d, please upgrade devcleaner", e.Version, CurrentManifestVersion)
+	}
+	return fmt.Sprintf("manifest version %d is no longer supported (oldest supported version is %d)", e.Version, MinManifestVersion)
+}
+
+// A migration upgrades a raw manifest document from the version it is
+// registered for to the next one. Migrations work on the raw document
+// rather than on Manifest so that they can handle renamed or removed fields.
+type migration func(doc map[string]any) error
+
+var migrations = map[int]migration{
+	0: migrateV0ToV1,
+	1: migrateV1ToV2,
+}
+
+// Version 0 manifests predate the version field, their layout is otherwise
+// identical to version 1.
+func migrateV0ToV1(doc map[string]any) error {
+	if _, ok := doc["apps"]; !ok {
+		doc["apps"] = []any{}
+	}
+	return nil
+}
+
+// Version 2 patterns gave a meaning to `*`, `?`, `<`, `>` and a leading `~`,
+// which version 1 patterns use literally. They are escaped so that version 1
+// manifests keep matching the same paths.
+func migrateV1ToV2(doc map[string]any) error {
+	apps, _ := doc["apps"].([]any)
+	for _, rawApp := range apps {
+		app, ok := rawApp.(map[string]any)
+		if !ok {
+			continue
+		}
+		if pattern, ok := app["path"].(string); ok {
+			app["path"] = escapeV1Pattern(pattern)
+		}
+		caches, _ := app["caches"].([]any)
+		for i, rawCache := range caches {
+			switch cache := rawCache.(type) {
+			case string:
+				caches[i] = escapeV1Pattern(cache)
+			case map[string]any:
+				if pattern, ok := cache["path"].(string); ok {
+					cache["path"] = escapeV1Pattern(pattern)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// The condition starting a branch of a version 1 placeholder, whose
+// operators must not be escaped.
+var v1Condition = regexp.MustCompile(`^(\{[A-Za-z0-9_.]+\}|[A-Za-z0-9_.+-]+)(==|!=|<=|>=|<|>)(\{[A-Za-z0-9_.]+\}|[A-Za-z0-9_.+-]+):`)
+
+// Escapes the characters of a version 1 pattern that became syntax in
+// version 2.
+func escapeV1Pattern(pattern string) string {
+	var b strings.Builder
+	if pattern == "~" || strings.HasPrefix(pattern, "~/") {
+		b.WriteByte('\\')
+	}
+	// innermost opening brace or bracket last
+	var open []byte
+	branchStart := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if branchStart {
+			branchStart = false
+			if condition := v1Condition.FindString(pattern[i:]); condition != "" {
+				b.WriteString(condition)
+				i += len(condition) - 1
+				continue
+			}
+		}
+		switch c {
+		case '\\':
+			b.WriteByte(c)
+			if i+1 < len(pattern) {
+				i++
+				b.WriteByte(pattern[i])
+			}
+			continue
+		case '{', '[':
+			open = append(open, c)
+			branchStart = c == '{'
+		case '}', ']':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case ',':
+			branchStart = len(open) > 0 && open[len(open)-1] == '{'
+		case '*', '?', '<', '>':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func documentVersion(doc map[string]any) (int, error) {
+	raw, ok := doc["version"]
+	if !ok || raw == nil {
+		return 0, nil
+	}
+	// YAML and TOML decode integers as such, JSON as float64
+	switch version := raw.(type) {
+	case int:
+		return version, nil
+	case int64:
+		return int(version), nil
+	case float64:
+		if version == float64(int(version)) {
+			return int(version), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid manifest version %v", raw)
+}
+
+// Upgrades doc to CurrentManifestVersion in place.
+// Returns whether any migration was applied.
+func migrateDocument(doc map[string]any) (bool, error) {
+	version, err := documentVersion(doc)
+	if err != nil {
+		return false, err
+	}
+	if version > CurrentManifestVersion || version < MinManifestVersion {
+		return false, &UnsupportedManifestVersionError{Version: version}
+	}
+	migrated := false
+	for ; version < CurrentManifestVersion; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return false, fmt.Errorf("no migration from manifest version %d", version)
+		}
+		if err := migrate(doc); err != nil {
+			return false, fmt.Errorf("error migrating manifest from version %d (%s)", version, err)
+		}
+		doc["version"] = float64(version + 1)
+		migrated = true
+	}
+	return migrated, nil
+}
+
+// Decodes a JSON manifest, migrating it to CurrentManifestVersion if needed.
+func DecodeManifest(data []byte) (*Manifest, bool, error) {
+	return DecodeManifestAs(data, FormatJSON)
+}
+
+// Decodes a manifest in the given format, migrating it to
+// CurrentManifestVersion if needed.
+func DecodeManifestAs(data []byte, format ManifestFormat) (manifest *Manifest, migrated bool, err error) {
+	doc, err := format.decodeDocument(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if migrated, err = migrateDocument(doc); err != nil {
+		return nil, false, err
+	}
+	if migrated || format != FormatJSON {
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, false, err
+		}
+	}
+	manifest = &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, false, err
+	}
+	return manifest, migrated, nil
+}
@@ -0,0 +1,57 @@
+package apps
+
+import (
+	"testing"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCurrentManifest(t *testing.T) {
+	manifest, migrated, err := DecodeManifest([]byte(`{"apps":[{"name":"cargo","path":"{env.HOME}/.cargo/bin/cargo","caches":["{env.HOME}/.cargo/registry"]}],"version":2}`))
+	assert.NoError(t, err)
+	assert.False(t, migrated)
+	assert.Equal(t, CurrentManifestVersion, manifest.Version)
+	assert.Equal(t, "cargo", manifest.Apps[0].Name)
+}
+
+func TestDecodeMigratesUnversionedManifest(t *testing.T) {
+	manifest, migrated, err := DecodeManifest([]byte(`{"apps":[{"name":"cargo","path":"/bin/cargo","caches":[]}]}`))
+	assert.NoError(t, err)
+	assert.True(t, migrated)
+	assert.Equal(t, CurrentManifestVersion, manifest.Version)
+	assert.Len(t, manifest.Apps, 1)
+}
+
+func TestDecodeRefusesNewerManifest(t *testing.T) {
+	_, _, err := DecodeManifest([]byte(`{"apps":[],"version":99}`))
+	var unsupported *UnsupportedManifestVersionError
+	assert.ErrorAs(t, err, &unsupported)
+	assert.Equal(t, 99, unsupported.Version)
+	assert.Contains(t, err.Error(), "please upgrade devcleaner")
+}
+
+func TestDecodeRejectsInvalidVersion(t *testing.T) {
+	_, _, err := DecodeManifest([]byte(`{"apps":[],"version":"one"}`))
+	assert.Error(t, err)
+}
+
+func TestDecodeEscapesVersion1Syntax(t *testing.T) {
+	manifest, migrated, err := DecodeManifest([]byte(`{"apps":[{"name":"odd","path":"~/bin/<odd>",` +
+		`"caches":["/var/log/*.?","{version>=2:/opt/a*,/opt/b}",{"path":"[/x<1>,{env.HOME}/y?]","risk":"safe"},"\\*"]}],"version":1}`))
+	assert.NoError(t, err)
+	assert.True(t, migrated)
+	assert.Equal(t, CurrentManifestVersion, manifest.Version)
+	app := manifest.Apps[0]
+	assert.Equal(t, `\~/bin/\<odd\>`, string(app.Path))
+	assert.Equal(t, `/var/log/\*.\?`, string(app.Caches[0].Path))
+	assert.Equal(t, `{version>=2:/opt/a\*,/opt/b}`, string(app.Caches[1].Path))
+	assert.Equal(t, `[/x\<1\>,{env.HOME}/y\?]`, string(app.Caches[2].Path))
+	assert.Equal(t, RiskSafe, app.Caches[2].Risk)
+	// already escaped
+	assert.Equal(t, `\*`, string(app.Caches[3].Path))
+	for _, pattern := range []string{string(app.Path), string(app.Caches[0].Path), string(app.Caches[1].Path), string(app.Caches[2].Path)} {
+		_, err := path.Parse(pattern)
+		assert.NoError(t, err, pattern)
+	}
+}
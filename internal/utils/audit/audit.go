@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	dio "github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+)
+
+// How a cache was removed.
+type Method string
+
+const MethodDelete = Method("delete")
+
+type Result string
+
+const (
+	ResultDeleted = Result("deleted")
+	ResultFailed  = Result("failed")
+	// Refused by the safety checks, e.g. the path changed since the scan
+	ResultRefused = Result("refused")
+)
+
+// A deletion performed by a clean.
+type Record struct {
+	Time time.Time `json:"time"`
+	// User running the clean
+	User string `json:"user"`
+	Host string `json:"host"`
+	// User whose caches are cleaned, if not the one running the clean
+	Owner string `json:"owner,omitempty"`
+	// Why the clean ran, e.g. clean, schedule or watch
+	Reason string `json:"reason"`
+	App    string `json:"app"`
+	Path   string `json:"path"`
+	// Pattern of the manifest the path was resolved from
+	Pattern         string    `json:"pattern"`
+	ManifestVersion int       `json:"manifest_version"`
+	ManifestUpdated time.Time `json:"manifest_updated"`
+	Bytes           int64     `json:"bytes"`
+	Method          Method    `json:"method"`
+	Result          Result    `json:"result"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Appends a record to the log, a file of one JSON object per line that is
+// only ever appended to.
+func Append(file string, r Record) error {
+	lock, err := dio.LockFile(file + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return dio.AppendLine(file, line, 0600)
+}
+
+// Selects records, zero fields match every record.
+type Filter struct {
+	Since time.Time
+	// Excluded
+	Until time.Time
+	App   string
+}
+
+func (f *Filter) matches(r *Record) bool {
+	return (f.Since.IsZero() || !r.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || r.Time.Before(f.Until)) &&
+		(f.App == "" || strings.EqualFold(f.App, r.App))
+}
+
+// Returns the records of the log matching the filter, in the order they were
+// written. Lines that cannot be decoded, e.g. written partially, are
+// skipped.
+func Query(file string, f Filter) ([]Record, error) {
+	log, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer log.Close()
+	var records []Record
+	scanner := bufio.NewScanner(log)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if f.matches(&r) {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
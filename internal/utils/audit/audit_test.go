@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendQuery(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "devcleaner", "audit.jsonl")
+	records, err := Query(file, Filter{})
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	day := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	for i, app := range []string{"npm", "cargo", "npm"} {
+		assert.NoError(t, Append(file, Record{
+			Time:   day.Add(time.Duration(i) * 24 * time.Hour),
+			User:   "root",
+			Host:   "ci-1",
+			Owner:  "alice",
+			Reason: "clean",
+			App:    app,
+			Path:   "/home/alice/." + app,
+			Bytes:  int64(i+1) << 20,
+			Method: MethodDelete,
+			Result: ResultDeleted,
+		}))
+	}
+	// interrupted write, followed by a clean
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	f.WriteString(`{"time":"2026-10-`)
+	f.Close()
+	assert.NoError(t, Append(file, Record{Time: day.Add(72 * time.Hour), App: "go", Method: MethodDelete, Result: ResultFailed}))
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	records, err = Query(file, Filter{})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 4) {
+		assert.Equal(t, "go", records[3].App)
+		assert.Equal(t, "alice", records[0].Owner)
+		assert.Equal(t, int64(1<<20), records[0].Bytes)
+		assert.Equal(t, MethodDelete, records[0].Method)
+	}
+
+	records, err = Query(file, Filter{App: "NPM"})
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	records, err = Query(file, Filter{Since: day.Add(time.Hour), Until: day.Add(48 * time.Hour)})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "cargo", records[0].App)
+	}
+}
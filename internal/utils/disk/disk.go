@@ -0,0 +1,100 @@
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+)
+
+var errUnsupported = errors.New("not supported on " + runtime.GOOS)
+
+// Space of a filesystem, in bytes.
+type Usage struct {
+	Total int64
+	// Available to unprivileged users
+	Free int64
+}
+
+// An amount of free space, either a percentage of the filesystem or a
+// number of bytes.
+type Threshold struct {
+	Percent float64
+	Bytes   int64
+}
+
+// Parses a threshold such as 10% or 20G.
+func ParseThreshold(s string) (Threshold, error) {
+	if percent, ok := strings.CutSuffix(strings.TrimSpace(s), "%"); ok {
+		value, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || value < 0 || value > 100 {
+			return Threshold{}, fmt.Errorf("invalid percentage %q", s)
+		}
+		return Threshold{Percent: value}, nil
+	}
+	bytes, err := io.ParseBytes(s)
+	if err != nil {
+		return Threshold{}, err
+	}
+	return Threshold{Bytes: bytes}, nil
+}
+
+// Returns the number of bytes of the threshold on a filesystem.
+func (t Threshold) Of(u Usage) int64 {
+	if t.Bytes > 0 {
+		return t.Bytes
+	}
+	return int64(t.Percent / 100 * float64(u.Total))
+}
+
+func (t Threshold) String() string {
+	if t.Bytes > 0 {
+		return io.HumanizeBytes(t.Bytes)
+	}
+	return strconv.FormatFloat(t.Percent, 'f', -1, 64) + "%"
+}
+
+// Returns the mount point of the filesystem containing path, or the link
+// itself if path is a symbolic link, see LinkDevice.
+func LinkMountPoint(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		return MountPoint(filepath.Dir(path))
+	}
+	return MountPoint(path)
+}
+
+// Returns the mount point of the filesystem containing path, the topmost
+// directory above it on the same device.
+func MountPoint(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	device, err := Device(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		if d, err := Device(parent); err != nil || d != device {
+			return path, nil
+		}
+		path = parent
+	}
+}
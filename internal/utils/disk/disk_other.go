@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !windows
+
+package disk
+
+// Returns the space of the filesystem containing path.
+func GetUsage(path string) (Usage, error) {
+	return Usage{}, errUnsupported
+}
+
+// Returns the ID of the device containing path, which is the same for all the
+// files of a filesystem.
+func Device(path string) (uint64, error) {
+	return 0, errUnsupported
+}
+
+// Returns the ID of the device containing path, or the link itself if path
+// is a symbolic link.
+func LinkDevice(path string) (uint64, error) {
+	return 0, errUnsupported
+}
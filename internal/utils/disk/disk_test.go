@@ -0,0 +1,81 @@
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseThreshold(t *testing.T) {
+	for s, expected := range map[string]Threshold{
+		"10%":    {Percent: 10},
+		"2.5 %":  {Percent: 2.5},
+		"20G":    {Bytes: 20 << 30},
+		"1.5 GB": {Bytes: 3 << 29},
+		"512MiB": {Bytes: 512 << 20},
+		"4096":   {Bytes: 4096},
+	} {
+		threshold, err := ParseThreshold(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, threshold, s)
+	}
+	for _, s := range []string{"", "%", "120%", "-1G", "10X", "lots"} {
+		_, err := ParseThreshold(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestThresholdOf(t *testing.T) {
+	usage := Usage{Total: 200 << 30, Free: 10 << 30}
+	assert.Equal(t, int64(20<<30), Threshold{Percent: 10}.Of(usage))
+	assert.Equal(t, int64(5<<30), Threshold{Bytes: 5 << 30}.Of(usage))
+	assert.Equal(t, "10%", Threshold{Percent: 10}.String())
+	assert.Equal(t, "5.0 GB", Threshold{Bytes: 5 << 30}.String())
+}
+
+func TestGetUsage(t *testing.T) {
+	usage, err := GetUsage(t.TempDir())
+	assert.NoError(t, err)
+	assert.Greater(t, usage.Total, int64(0))
+	assert.LessOrEqual(t, usage.Free, usage.Total)
+
+	dir := t.TempDir()
+	device, err := Device(dir)
+	assert.NoError(t, err)
+	parent, err := Device(dir + "/..")
+	assert.NoError(t, err)
+	assert.Equal(t, parent, device)
+}
+
+func TestLinkDevice(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges")
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	assert.NoError(t, os.Symlink(filepath.Join(t.TempDir(), "missing"), link))
+	// Device follows the link, LinkDevice reads the link itself
+	_, err := Device(link)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	device, err := LinkDevice(link)
+	assert.NoError(t, err)
+	dirDevice, _ := Device(filepath.Dir(link))
+	assert.Equal(t, dirDevice, device)
+}
+
+func TestMountPoint(t *testing.T) {
+	dir := t.TempDir()
+	mount, err := MountPoint(dir)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dir, mount), "%s is not above %s", mount, dir)
+	device, _ := Device(dir)
+	mountDevice, _ := Device(mount)
+	assert.Equal(t, device, mountDevice)
+
+	root, err := MountPoint("/")
+	assert.NoError(t, err)
+	assert.Equal(t, "/", root)
+}
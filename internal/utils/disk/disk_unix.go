@@ -0,0 +1,37 @@
+//go:build linux || darwin
+
+package disk
+
+import "golang.org/x/sys/unix"
+
+// Returns the space of the filesystem containing path.
+func GetUsage(path string) (Usage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return Usage{}, err
+	}
+	return Usage{
+		Total: int64(st.Blocks) * int64(st.Bsize),
+		Free:  int64(st.Bavail) * int64(st.Bsize),
+	}, nil
+}
+
+// Returns the ID of the device containing path, which is the same for all the
+// files of a filesystem. Symbolic links are followed.
+func Device(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
+
+// Returns the ID of the device containing path, or the link itself if path
+// is a symbolic link, i.e. the filesystem on which deleting path frees space.
+func LinkDevice(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
@@ -0,0 +1,40 @@
+package disk
+
+import "golang.org/x/sys/windows"
+
+// Returns the space of the filesystem containing path.
+func GetUsage(path string) (Usage, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return Usage{}, err
+	}
+	var free, total uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &free, &total, nil); err != nil {
+		return Usage{}, err
+	}
+	return Usage{Total: int64(total), Free: int64(free)}, nil
+}
+
+// Returns the ID of the device containing path, or the link itself if path
+// is a symbolic link. The volume of a path is that of its link.
+func LinkDevice(path string) (uint64, error) {
+	return Device(path)
+}
+
+// Returns the ID of the device containing path, which is the serial number
+// of its volume.
+func Device(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	volume := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(p, &volume[0], uint32(len(volume))); err != nil {
+		return 0, err
+	}
+	var serial uint32
+	if err := windows.GetVolumeInformation(&volume[0], nil, 0, &serial, nil, nil, nil, 0); err != nil {
+		return 0, err
+	}
+	return uint64(serial), nil
+}
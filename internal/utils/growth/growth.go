@@ -0,0 +1,192 @@
+package growth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	dio "github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+)
+
+// The disk usage of the caches found by a scan.
+type Snapshot struct {
+	Time time.Time `json:"time"`
+	// User or root scanned, empty for the current system
+	System string `json:"system,omitempty"`
+	// Bytes by app name
+	Apps map[string]int64 `json:"apps"`
+	// Bytes by cache path
+	Caches map[string]int64 `json:"caches"`
+}
+
+// Number of snapshots of each system kept in the store, older ones are
+// dropped once a system has a quarter more.
+var maxSnapshots = 1000
+
+// Appends a snapshot to the store, a file of one JSON object per line, and
+// compacts it when it grows too large.
+func Append(file string, s Snapshot) error {
+	lock, err := dio.LockFile(file + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	line, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := dio.AppendLine(file, line, 0644); err != nil {
+		return err
+	}
+	return compact(file)
+}
+
+// Drops the oldest snapshots of the systems having more than maxSnapshots,
+// and the lines that cannot be decoded. The store must be locked.
+func compact(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var lines [][]byte
+	var systems []string
+	counts := map[string]int{}
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		var s Snapshot
+		if err := json.Unmarshal(line, &s); err != nil {
+			continue
+		}
+		lines = append(lines, line)
+		systems = append(systems, s.System)
+		counts[s.System]++
+	}
+	compacted := false
+	for _, count := range counts {
+		compacted = compacted || count > maxSnapshots+maxSnapshots/4
+	}
+	if !compacted {
+		return nil
+	}
+	var kept []byte
+	for i, line := range lines {
+		// snapshots are appended in the order of the scans
+		if counts[systems[i]] > maxSnapshots {
+			counts[systems[i]]--
+			continue
+		}
+		kept = append(append(kept, line...), '\n')
+	}
+	return dio.WriteFileAtomic(file, kept, 0644)
+}
+
+// Returns the snapshots of the store of system, oldest first. Lines that
+// cannot be decoded, e.g. written partially, are skipped.
+func Load(file string, system string) ([]Snapshot, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var snapshots []Snapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var s Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil || s.System != system {
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, scanner.Err()
+}
+
+// How the size of an app or a cache evolved.
+type Trend struct {
+	Name string
+	// Size at each snapshot it was found in, which skips the scans in which
+	// it was missing, excluded or unreadable
+	Sizes []int64
+	// Bytes per week between the first and the last snapshots it was found in
+	WeeklyGrowth int64
+	// Bytes since the previous snapshot it was found in, 0 if it was not
+	// found by the last one
+	Change int64
+}
+
+func (t *Trend) Current() int64 {
+	return t.Sizes[len(t.Sizes)-1]
+}
+
+const week = 7 * 24 * time.Hour
+
+// Returns the trends of the apps or caches, as returned by sizes, of
+// snapshots sorted oldest first. Trends are sorted by decreasing weekly
+// growth.
+func Trends(snapshots []Snapshot, sizes func(Snapshot) map[string]int64) []Trend {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	var names []string
+	for _, s := range snapshots {
+		for name := range sizes(s) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	last := len(snapshots) - 1
+	trends := make([]Trend, len(names))
+	for i, name := range names {
+		t := Trend{Name: name}
+		var first, latest time.Time
+		for _, s := range snapshots {
+			if size, ok := sizes(s)[name]; ok {
+				if t.Sizes == nil {
+					first = s.Time
+				}
+				t.Sizes = append(t.Sizes, size)
+				latest = s.Time
+			}
+		}
+		n := len(t.Sizes)
+		if elapsed := latest.Sub(first); elapsed > 0 {
+			t.WeeklyGrowth = int64(float64(t.Sizes[n-1]-t.Sizes[0]) / float64(elapsed) * float64(week))
+		}
+		if _, ok := sizes(snapshots[last])[name]; ok && n > 1 {
+			t.Change = t.Sizes[n-1] - t.Sizes[n-2]
+		}
+		trends[i] = t
+	}
+	sort.SliceStable(trends, func(i, j int) bool { return trends[i].WeeklyGrowth > trends[j].WeeklyGrowth })
+	return trends
+}
+
+// Returns the n trends that changed the most since the previous snapshot,
+// growing or shrinking.
+func BiggestMovers(trends []Trend, n int) []Trend {
+	var movers []Trend
+	for _, t := range trends {
+		if t.Change != 0 {
+			movers = append(movers, t)
+		}
+	}
+	sort.SliceStable(movers, func(i, j int) bool { return abs(movers[i].Change) > abs(movers[j].Change) })
+	return movers[:min(n, len(movers))]
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
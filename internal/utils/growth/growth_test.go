@@ -0,0 +1,91 @@
+package growth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "devcleaner", "usage.jsonl")
+	snapshots, err := Load(file, "")
+	assert.NoError(t, err)
+	assert.Empty(t, snapshots)
+
+	start := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, Append(file, Snapshot{Time: start.Add(time.Hour), Apps: map[string]int64{"npm": 2}}))
+	assert.NoError(t, Append(file, Snapshot{Time: start, Apps: map[string]int64{"npm": 1}}))
+	assert.NoError(t, Append(file, Snapshot{Time: start, System: "alice", Apps: map[string]int64{"npm": 3}}))
+	// interrupted write
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	f.WriteString(`{"time":"2026-09-`)
+	f.Close()
+	assert.NoError(t, Append(file, Snapshot{Time: start.Add(2 * time.Hour), Apps: map[string]int64{"npm": 4}}))
+
+	snapshots, err = Load(file, "")
+	assert.NoError(t, err)
+	if assert.Len(t, snapshots, 3) {
+		assert.Equal(t, int64(1), snapshots[0].Apps["npm"])
+		assert.Equal(t, int64(2), snapshots[1].Apps["npm"])
+		assert.Equal(t, int64(4), snapshots[2].Apps["npm"])
+	}
+	snapshots, err = Load(file, "alice")
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+}
+
+func TestTrends(t *testing.T) {
+	start := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	snapshots := []Snapshot{
+		{Time: start, Apps: map[string]int64{"npm": 100, "cargo": 500, "pip": 80}},
+		{Time: start.Add(7 * day), Apps: map[string]int64{"npm": 300, "cargo": 400, "go": 50, "yarn": 40}},
+		{Time: start.Add(14 * day), Apps: map[string]int64{"npm": 500, "cargo": 300, "go": 60, "pip": 100}},
+	}
+	trends := Trends(snapshots, func(s Snapshot) map[string]int64 { return s.Apps })
+	if assert.Len(t, trends, 5) {
+		assert.Equal(t, Trend{Name: "npm", Sizes: []int64{100, 300, 500}, WeeklyGrowth: 200, Change: 200}, trends[0])
+		// found a week after the first scan
+		assert.Equal(t, Trend{Name: "go", Sizes: []int64{50, 60}, WeeklyGrowth: 10, Change: 10}, trends[1])
+		// missing from the second scan, e.g. unreadable
+		assert.Equal(t, Trend{Name: "pip", Sizes: []int64{80, 100}, WeeklyGrowth: 10, Change: 20}, trends[2])
+		// missing from the last scan, it did not shrink
+		assert.Equal(t, Trend{Name: "yarn", Sizes: []int64{40}}, trends[3])
+		assert.Equal(t, Trend{Name: "cargo", Sizes: []int64{500, 400, 300}, WeeklyGrowth: -100, Change: -100}, trends[4])
+		assert.Equal(t, int64(500), trends[0].Current())
+	}
+
+	movers := BiggestMovers(trends, 2)
+	assert.Equal(t, []string{"npm", "cargo"}, []string{movers[0].Name, movers[1].Name})
+	assert.Empty(t, BiggestMovers(Trends(snapshots[:1], func(s Snapshot) map[string]int64 { return s.Apps }), 5))
+}
+
+func TestAppendCompacts(t *testing.T) {
+	defer func(n int) { maxSnapshots = n }(maxSnapshots)
+	maxSnapshots = 4
+	file := filepath.Join(t.TempDir(), "usage.jsonl")
+	start := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		assert.NoError(t, Append(file, Snapshot{Time: start.Add(time.Duration(i) * time.Hour), System: "alice"}))
+	}
+	for i := range 6 {
+		snapshots, err := Load(file, "")
+		assert.NoError(t, err)
+		assert.Len(t, snapshots, i)
+		assert.NoError(t, Append(file, Snapshot{Time: start.Add(time.Duration(i) * time.Hour)}))
+	}
+	// compacted once a system has a quarter more than maxSnapshots
+	snapshots, err := Load(file, "")
+	assert.NoError(t, err)
+	if assert.Len(t, snapshots, 4) {
+		assert.Equal(t, start.Add(2*time.Hour), snapshots[0].Time)
+	}
+	// other systems are trimmed along
+	snapshots, err = Load(file, "alice")
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 4)
+}
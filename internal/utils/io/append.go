@@ -0,0 +1,38 @@
+package io
+
+import (
+	"io"
+	"os"
+	"slices"
+)
+
+// Appends line and a newline to name, creating it with perm. A newline is
+// written first if name does not end with one, e.g. after an interrupted
+// write, so that the partial line does not swallow this one. Writers must
+// hold a lock on name.
+func AppendLine(name string, line []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_RDWR, perm)
+	if err != nil {
+		return err
+	}
+	// a copy, line may have room for the newline in its array
+	data := append(slices.Clip(line), '\n')
+	last := make([]byte, 1)
+	if info, err := f.Stat(); err != nil {
+		f.Close()
+		return err
+	} else if info.Size() > 0 {
+		if _, err := f.ReadAt(last, info.Size()-1); err != nil && err != io.EOF {
+			f.Close()
+			return err
+		}
+		if last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
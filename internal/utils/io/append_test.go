@@ -0,0 +1,34 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendLine(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log.jsonl")
+	assert.NoError(t, AppendLine(name, []byte("first"), 0600))
+	assert.NoError(t, AppendLine(name, []byte("second"), 0600))
+	// interrupted write
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	f.WriteString("thi")
+	f.Close()
+	assert.NoError(t, AppendLine(name, []byte("third"), 0600))
+
+	data, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nsecond\nthi\nthird\n", string(data))
+}
+
+func TestAppendLineKeepsTheBufferOfTheCaller(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log.jsonl")
+	buf := make([]byte, 0, 16)
+	line := append(buf, "first"...)
+	assert.NoError(t, AppendLine(name, line, 0600))
+	assert.Equal(t, "first", string(line))
+	assert.Equal(t, byte(0), buf[:cap(buf)][len(line)], "the newline was written to the buffer")
+}
@@ -0,0 +1,35 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "sub", "manifest.json")
+	assert.NoError(t, WriteFileAtomic(name, []byte("first"), 0600))
+	assert.NoError(t, WriteFileAtomic(name, []byte("second"), 0644))
+
+	data, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+	entries, err := os.ReadDir(filepath.Dir(name))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files are left behind")
+}
+
+func TestWriteFileAtomicKeepsOldFileOnError(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "manifest.json")
+	assert.NoError(t, WriteFileAtomic(name, []byte("old"), 0644))
+	// renaming a file over a non-empty directory fails
+	assert.Error(t, WriteFileAtomic(dir, []byte("new"), 0644))
+
+	data, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", string(data))
+}
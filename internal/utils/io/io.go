@@ -2,61 +2,72 @@ package io
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
 
-// Recursively calculates the disk usage of a directory
+// Reads a directory, replaced in tests.
+var readDir = os.ReadDir
+
+// Recursively calculates the disk usage of a directory, or returns the size of a file
 // This uses goroutines to parallelize the calculation
 func DiskUsage(path string) (int64, error) {
-	// fmt.Println("DiskUsage", path)
+	// globs can match files as well as directories
+	if info, err := os.Lstat(path); err != nil {
+		return 0, err
+	} else if !info.IsDir() {
+		return info.Size(), nil
+	}
 	var size atomic.Int64
 	var wg sync.WaitGroup
-	errors := make(chan error)
-	defer close(errors)
+	var mu sync.Mutex
+	var errs []error
+	report := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
 
 	wg.Add(1)
-	go diskUsage(path, &size, errors, &wg)
+	go diskUsage(path, &size, report, &wg)
 	wg.Wait()
-	// drain all the values from the channel
-	for len(errors) > 0 {
-		if err := <-errors; err != nil {
-			fmt.Printf("DiskUsage(%s): error: %s\n", path, err)
-			return 0, err
-		}
+	switch len(errs) {
+	case 0:
+		return size.Load(), nil
+	case 1:
+		return 0, errs[0]
+	default:
+		return 0, fmt.Errorf("%s, and %d other errors", errs[0], len(errs)-1)
 	}
-
-	total := size.Load()
-	// fmt.Printf("DiskUsage(%s): total: %d\n", path, total)
-
-	return total, nil
 }
 
-func diskUsage(path string, size *atomic.Int64, errors chan error, wg *sync.WaitGroup) {
-	// fmt.Printf("diskUsage(%s): Starting\n", path)
+func diskUsage(path string, size *atomic.Int64, report func(error), wg *sync.WaitGroup) {
 	defer func() {
 		if r := recover(); r != nil {
-			errors <- fmt.Errorf("panic in diskUsage: %s", r)
+			report(fmt.Errorf("panic in diskUsage: %s", r))
 		}
 		wg.Done()
 	}()
 
-	dir, err := os.ReadDir(path)
+	dir, err := readDir(path)
 	if err != nil {
-		errors <- fmt.Errorf("error reading directory %s: %s", path, err)
+		report(fmt.Errorf("error reading directory %s: %s", path, err))
 		return
 	}
 	for _, entry := range dir {
 		if entry.IsDir() {
 			wg.Add(1)
-			go diskUsage(filepath.Join(path, entry.Name()), size, errors, wg)
+			go diskUsage(filepath.Join(path, entry.Name()), size, report, wg)
 			continue
 		}
 		info, err := entry.Info()
 		if err != nil {
-			errors <- fmt.Errorf("error getting info for %s: %s", entry.Name(), err)
+			report(fmt.Errorf("error getting info for %s: %s", filepath.Join(path, entry.Name()), err))
 			continue
 		}
 		size.Add(info.Size())
@@ -76,3 +87,22 @@ func HumanizeBytes(size int64) string {
 	return fmt.Sprintf("%.1f %cB",
 		float64(size)/float64(div), "kMGTPE"[exp])
 }
+
+// Parses a size written like HumanizeBytes does, e.g. 512, 10k, 1.5 GB or
+// 2GiB. Units are powers of 1024.
+func ParseBytes(s string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSpace(s))
+	number = strings.TrimSuffix(strings.TrimSuffix(number, "B"), "I")
+	exp := 0
+	if n := len(number); n > 0 {
+		if i := strings.IndexByte("KMGTPE", number[n-1]); i >= 0 {
+			exp = i + 1
+			number = number[:n-1]
+		}
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * math.Pow(1024, float64(exp))), nil
+}
@@ -0,0 +1,53 @@
+package io
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskUsage(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a", "one"), make([]byte, 10), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a", "b", "two"), make([]byte, 32), 0644))
+
+	size, err := DiskUsage(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), size)
+	size, err = DiskUsage(filepath.Join(dir, "a", "one"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), size)
+}
+
+func TestDiskUsageReportsUnreadableDirectories(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"a", "b", "c"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, sub), 0755))
+	}
+	// permissions do not apply to root, fail the reads instead
+	readDir = func(name string) ([]os.DirEntry, error) {
+		if name != dir {
+			return nil, errors.New("permission denied")
+		}
+		return os.ReadDir(name)
+	}
+	t.Cleanup(func() { readDir = os.ReadDir })
+
+	done := make(chan error)
+	go func() {
+		_, err := DiskUsage(dir)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "permission denied")
+		assert.ErrorContains(t, err, "and 2 other errors")
+	case <-time.After(5 * time.Second):
+		t.Fatal("DiskUsage is stuck")
+	}
+}
@@ -0,0 +1,37 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// An advisory lock on a file, shared with other processes using LockFile
+// on the same path.
+type FileLock struct {
+	file *os.File
+}
+
+// Blocks until an exclusive advisory lock on name is acquired.
+// The file is created if it does not exist.
+func LockFile(name string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FileLock{file: file}, nil
+}
+
+func (l *FileLock) Unlock() error {
+	if err := unlockFile(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
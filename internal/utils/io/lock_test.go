@@ -0,0 +1,72 @@
+package io
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockFileExcludesOtherHolders(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "manifest.json.lock")
+	first, err := LockFile(name)
+	assert.NoError(t, err)
+
+	var acquired atomic.Bool
+	done := make(chan error)
+	go func() {
+		second, err := LockFile(name)
+		if err != nil {
+			done <- err
+			return
+		}
+		acquired.Store(true)
+		done <- second.Unlock()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, acquired.Load(), "the lock was acquired while held")
+	assert.NoError(t, first.Unlock())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.True(t, acquired.Load())
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock was not acquired after being released")
+	}
+}
+
+func TestLockFileSerializesWriters(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "counter.lock")
+	var counter, concurrent, maxConcurrent atomic.Int32
+	done := make(chan error)
+	for range 2 {
+		go func() {
+			for range 20 {
+				lock, err := LockFile(name)
+				if err != nil {
+					done <- err
+					return
+				}
+				n := concurrent.Add(1)
+				if n > maxConcurrent.Load() {
+					maxConcurrent.Store(n)
+				}
+				counter.Add(1)
+				time.Sleep(time.Millisecond)
+				concurrent.Add(-1)
+				if err := lock.Unlock(); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+	}
+	assert.NoError(t, <-done)
+	assert.NoError(t, <-done)
+	assert.Equal(t, int32(40), counter.Load())
+	assert.Equal(t, int32(1), maxConcurrent.Load())
+}
@@ -0,0 +1,135 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Returns a slog.Logger writing to l, for libraries logging with slog.
+// Attributes are appended to the message as key=value.
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(&slogHandler{l: l})
+}
+
+type slogHandler struct {
+	l     *Logger
+	attrs []slog.Attr
+	group string
+}
+
+func slogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.CurrentLevel <= slogLevel(level)
+}
+
+func (h *slogHandler) Handle(_ context.Context, record slog.Record) error {
+	var b strings.Builder
+	b.WriteString(record.Message)
+	for _, attr := range h.attrs {
+		writeAttr(&b, "", attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		writeAttr(&b, h.group, attr)
+		return true
+	})
+	h.l.log(slogLevel(record.Level), "%s", b.String())
+	return nil
+}
+
+func writeAttr(b *strings.Builder, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	key := attr.Key
+	if group != "" {
+		key = group + "." + key
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, a := range attr.Value.Group() {
+			writeAttr(b, key, a)
+		}
+		return
+	}
+	fmt.Fprintf(b, " %s=%v", key, attr.Value)
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	handler.attrs = append(handler.attrs[:len(handler.attrs):len(handler.attrs)], attrs...)
+	if h.group != "" {
+		for i := len(h.attrs); i < len(handler.attrs); i++ {
+			handler.attrs[i].Key = h.group + "." + handler.attrs[i].Key
+		}
+	}
+	return &handler
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	handler := *h
+	if h.group != "" {
+		name = h.group + "." + name
+	}
+	handler.group = name
+	return &handler
+}
+
+// Returns a slog.Handler passing records to each of the handlers.
+func Tee(handlers ...slog.Handler) slog.Handler {
+	return teeHandler(handlers)
+}
+
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,235 @@
+package path
+
+import (
+	"strings"
+)
+
+// A node of a parsed PathPattern. Positions are byte offsets in the pattern.
+type Node interface {
+	Position() int
+	String() string
+	node()
+}
+
+// A sequence of nodes, evaluated by concatenating the value of each node.
+// The whole pattern, the options of either and all-of and the values of
+// placeholders are sequences.
+type Sequence []Node
+
+// Literal text, escape sequences are already resolved.
+type Literal struct {
+	Pos   int
+	Value string
+}
+
+// A `*` or `?` glob wildcard.
+type Wildcard struct {
+	Pos  int
+	Char rune
+}
+
+// `{name}`, or `{name:-default}` to use default when the variable is not set.
+type Variable struct {
+	Pos        int
+	Name       string
+	Default    Sequence
+	HasDefault bool
+}
+
+// `{cmd:command}`, the lines printed by the command.
+type Command struct {
+	Pos     int
+	Command string
+}
+
+// `[option1,option2,...]`, the first option that exists.
+type Either struct {
+	Pos     int
+	Options []Sequence
+}
+
+// `<option1,option2,...>`, every option that exists.
+type AllOf struct {
+	Pos     int
+	Options []Sequence
+}
+
+// `{cond1:value1,cond2:value2,default}`
+type Placeholder struct {
+	Pos      int
+	Branches []Branch
+	// Value used when no condition holds, if HasDefault
+	Default    Sequence
+	HasDefault bool
+	// Whether evaluation fails when no condition holds (`!` default)
+	Required bool
+}
+
+type Branch struct {
+	Condition Condition
+	Value     Sequence
+}
+
+// `left op right`, or only `right` when Short, in which case left and op
+// are the ones of the previous branch.
+type Condition struct {
+	Pos   int
+	Left  Operand
+	Op    string
+	Right Operand
+	Short bool
+}
+
+// A variable, or a string or number literal.
+type Operand struct {
+	Pos        int
+	Value      string
+	IsVariable bool
+}
+
+func (n *Literal) Position() int     { return n.Pos }
+func (n *Wildcard) Position() int    { return n.Pos }
+func (n *Variable) Position() int    { return n.Pos }
+func (n *Command) Position() int     { return n.Pos }
+func (n *Either) Position() int      { return n.Pos }
+func (n *AllOf) Position() int       { return n.Pos }
+func (n *Placeholder) Position() int { return n.Pos }
+
+func (*Literal) node()     {}
+func (*Wildcard) node()    {}
+func (*Variable) node()    {}
+func (*Command) node()     {}
+func (*Either) node()      {}
+func (*AllOf) node()       {}
+func (*Placeholder) node() {}
+
+// Characters that are escaped wherever they appear in literals.
+const specialChars = `\{}[]<>*?`
+
+// Prints the sequence back as a pattern that parses to the same sequence.
+func (s Sequence) String() string {
+	var b strings.Builder
+	if len(s) > 0 {
+		// a leading `~` would be read back as {home}
+		if lit, ok := s[0].(*Literal); ok && strings.HasPrefix(lit.Value, "~") {
+			b.WriteByte('\\')
+		}
+	}
+	s.write(&b, "")
+	return b.String()
+}
+
+// escaped lists characters to escape in literals on top of specialChars,
+// which depends on where the sequence is nested.
+func (s Sequence) write(b *strings.Builder, escaped string) {
+	for _, n := range s {
+		if lit, ok := n.(*Literal); ok {
+			writeEscaped(b, lit.Value, escaped)
+		} else {
+			b.WriteString(n.String())
+		}
+	}
+}
+
+func writeEscaped(b *strings.Builder, s string, escaped string) {
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(specialChars, s[i]) >= 0 || strings.IndexByte(escaped, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+}
+
+func (n *Literal) String() string {
+	var b strings.Builder
+	writeEscaped(&b, n.Value, "")
+	return b.String()
+}
+
+func (n *Wildcard) String() string {
+	return string(n.Char)
+}
+
+func (n *Variable) String() string {
+	if !n.HasDefault {
+		return "{" + n.Name + "}"
+	}
+	var b strings.Builder
+	b.WriteString("{" + n.Name + ":-")
+	n.Default.write(&b, "")
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (n *Command) String() string {
+	var b strings.Builder
+	b.WriteString("{cmd:")
+	writeEscaped(&b, n.Command, "")
+	b.WriteByte('}')
+	return b.String()
+}
+
+func writeOptions(b *strings.Builder, open byte, options []Sequence, close byte) {
+	b.WriteByte(open)
+	for i, option := range options {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		option.write(b, ",")
+	}
+	b.WriteByte(close)
+}
+
+func (n *Either) String() string {
+	var b strings.Builder
+	writeOptions(&b, '[', n.Options, ']')
+	return b.String()
+}
+
+func (n *AllOf) String() string {
+	var b strings.Builder
+	writeOptions(&b, '<', n.Options, '>')
+	return b.String()
+}
+
+func (n *Placeholder) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, branch := range n.Branches {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(branch.Condition.String())
+		b.WriteByte(':')
+		branch.Value.write(&b, ",")
+	}
+	switch {
+	case n.Required:
+		b.WriteString(",!")
+	case n.HasDefault:
+		b.WriteByte(',')
+		// these would make the default look like a condition, or like `!`
+		n.Default.write(&b, ",:=!")
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (c Condition) String() string {
+	if c.Short {
+		return c.Right.String()
+	}
+	return c.Left.leftString() + c.Op + c.Right.String()
+}
+
+// Variables on the left of a condition are written without braces.
+func (o Operand) leftString() string {
+	return o.Value
+}
+
+func (o Operand) String() string {
+	if o.IsVariable {
+		return "{" + o.Value + "}"
+	}
+	return o.Value
+}
@@ -0,0 +1,167 @@
+package path
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultCommandTimeout = 5 * time.Second
+
+// How long the output of a command is waited for once it exited or timed
+// out, e.g. when a daemon it started inherited its output.
+const commandWaitDelay = 500 * time.Millisecond
+
+// Returned by the commands of contexts in safe mode, e.g. synthetic ones.
+var ErrSafeMode = errors.New("commands are disabled")
+
+// Runs a command and returns its standard output.
+type commandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func execCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = commandWaitDelay
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrWaitDelay) && ctx.Err() == nil {
+		// the command itself succeeded
+		err = nil
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s (%s)", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// Outputs of the commands of `{cmd:...}`, shared by all the evaluations
+// using the same PathContext so that each command runs at most once.
+type commandCache struct {
+	mu      sync.Mutex
+	results map[string]*commandResult
+}
+
+type commandResult struct {
+	once  sync.Once
+	lines []string
+	err   error
+}
+
+// Disables `{cmd:...}`, for environments where running tools is not wanted.
+func (c *PathContext) SetSafeMode(safe bool) {
+	c.safeMode = safe
+}
+
+// Runs the commands of `{cmd:...}` and version commands with run instead of
+// executing them, e.g. in tests.
+func (c *PathContext) SetCommandRunner(run func(ctx context.Context, name string, args ...string) ([]byte, error)) {
+	c.runCommand = run
+}
+
+// Returns a copy of the context only running the commands starting with one
+// of the allowed prefixes followed by positional arguments, e.g. "go env"
+// allows "go env GOCACHE" but not "go env -w GOFLAGS=...", for the patterns
+// of manifests that are not trusted.
+func (c *PathContext) RestrictCommands(allowed []string) *PathContext {
+	restricted := *c
+	restricted.commands = c.sharedCommands()
+	restricted.allowedCommands = append([]string{}, allowed...)
+	return &restricted
+}
+
+func (c *PathContext) commandAllowed(args []string) bool {
+	if c.allowedCommands == nil {
+		return true
+	}
+	for _, allowed := range c.allowedCommands {
+		prefix := strings.Fields(allowed)
+		if len(prefix) > 0 && len(prefix) <= len(args) && slices.Equal(prefix, args[:len(prefix)]) {
+			// options could change what the command does, e.g. write
+			// the configuration of the tool
+			return !slices.ContainsFunc(args[len(prefix):], func(arg string) bool {
+				return strings.HasPrefix(arg, "-")
+			})
+		}
+	}
+	return false
+}
+
+// Sets how long a command of `{cmd:...}` can run, DefaultCommandTimeout by
+// default.
+func (c *PathContext) SetCommandTimeout(timeout time.Duration) {
+	c.commandTimeout = timeout
+}
+
+// Contexts built without NewPathContext get their cache on first use.
+func (c *PathContext) sharedCommands() *commandCache {
+	if c.commands == nil {
+		c.commands = &commandCache{}
+	}
+	return c.commands
+}
+
+// Returns the non-empty lines printed by the command, running it only the
+// first time.
+func (c *PathContext) RunCommand(command string) ([]string, error) {
+	if c.safeMode {
+		return nil, ErrSafeMode
+	}
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	if !c.commandAllowed(args) {
+		return nil, fmt.Errorf("command %q is not allowed for this manifest", command)
+	}
+
+	commands := c.sharedCommands()
+	commands.mu.Lock()
+	if commands.results == nil {
+		commands.results = make(map[string]*commandResult)
+	}
+	result, ok := commands.results[command]
+	if !ok {
+		result = &commandResult{}
+		commands.results[command] = result
+	}
+	commands.mu.Unlock()
+
+	result.once.Do(func() {
+		timeout := c.commandTimeout
+		if timeout <= 0 {
+			timeout = DefaultCommandTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		run := c.runCommand
+		if run == nil {
+			run = execCommand
+		}
+		out, err := run(ctx, args[0], args[1:]...)
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		if err != nil {
+			result.err = err
+			return
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				result.lines = append(result.lines, line)
+			}
+		}
+		if len(result.lines) == 0 {
+			result.err = errors.New("no output")
+		}
+	})
+	return result.lines, result.err
+}
@@ -0,0 +1,117 @@
+package path
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+
+	"github.com/adrg/xdg"
+)
+
+// Well-known directories of the user a pattern is evaluated for, available
+// as `{home}`, `{xdg.cache}`, `{xdg.data}`, `{xdg.config}` and `{tmp}`.
+type userDirs struct {
+	home      string
+	xdgCache  string
+	xdgData   string
+	xdgConfig string
+	tmp       string
+}
+
+// Directories of the current user. The home directory does not depend on
+// HOME being set, and XDG overrides are honoured. When HOME is not set, the
+// XDG directories are derived from the home directory of the account, and
+// the home directory is left unset if it is unknown.
+func currentUserDirs() userDirs {
+	if home, err := os.UserHomeDir(); err == nil {
+		return userDirs{
+			home:      home,
+			xdgCache:  xdg.CacheHome,
+			xdgData:   xdg.DataHome,
+			xdgConfig: xdg.ConfigHome,
+			tmp:       os.TempDir(),
+		}
+	}
+	// the xdg package derives its directories from HOME, i.e. from /
+	var home string
+	if u, err := user.Current(); err == nil {
+		home = u.HomeDir
+	}
+	dirs := defaultUserDirs(home, runtime.GOOS)
+	for env, dir := range map[string]*string{
+		"XDG_CACHE_HOME":  &dirs.xdgCache,
+		"XDG_DATA_HOME":   &dirs.xdgData,
+		"XDG_CONFIG_HOME": &dirs.xdgConfig,
+	} {
+		if value := os.Getenv(env); filepath.IsAbs(value) {
+			*dir = value
+		}
+	}
+	dirs.tmp = os.TempDir()
+	return dirs
+}
+
+// Default directories of a user with the given home directory on goos,
+// for when they cannot be queried from the environment.
+func defaultUserDirs(home string, goos string) userDirs {
+	dirs := userDirs{home: home}
+	if home == "" {
+		return dirs
+	}
+	switch goos {
+	case "darwin":
+		dirs.xdgCache = filepath.Join(home, "Library", "Caches")
+		dirs.xdgData = filepath.Join(home, "Library", "Application Support")
+		dirs.xdgConfig = filepath.Join(home, "Library", "Application Support")
+		dirs.tmp = "/tmp"
+	case "windows":
+		local := filepath.Join(home, "AppData", "Local")
+		dirs.xdgCache = filepath.Join(local, "cache")
+		dirs.xdgData = local
+		dirs.xdgConfig = local
+		dirs.tmp = filepath.Join(local, "Temp")
+	default:
+		dirs.xdgCache = filepath.Join(home, ".cache")
+		dirs.xdgData = filepath.Join(home, ".local", "share")
+		dirs.xdgConfig = filepath.Join(home, ".config")
+		dirs.tmp = "/tmp"
+	}
+	return dirs
+}
+
+// Returns the value of a directory variable, and whether name is one.
+func (c *PathContext) userDir(name string) (string, bool, error) {
+	dirs := c.dirs
+	if dirs == (userDirs{}) {
+		// contexts built by hand only know about HOME
+		dirs = defaultUserDirs(c.GetEnv("HOME"), c.os)
+	}
+	var value string
+	switch name {
+	case "home":
+		value = dirs.home
+	case "xdg.cache":
+		value = dirs.xdgCache
+	case "xdg.data":
+		value = dirs.xdgData
+	case "xdg.config":
+		value = dirs.xdgConfig
+	case "tmp":
+		value = dirs.tmp
+	default:
+		return "", false, nil
+	}
+	if value == "" {
+		return "", true, fmt.Errorf("%s directory %w", name, ErrUnset)
+	}
+	return value, true, nil
+}
+
+// Returns the value of a directory variable such as home or xdg.cache, or an
+// empty string if it is unknown.
+func (c *PathContext) Dir(name string) string {
+	value, _, _ := c.userDir(name)
+	return value
+}
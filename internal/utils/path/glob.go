@@ -0,0 +1,271 @@
+package path
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A character of an evaluated path, which is either a literal or one of
+// the glob metacharacters `*` and `?` written unescaped in the pattern.
+type globRune struct {
+	r    rune
+	meta bool
+}
+
+// An evaluated path that may contain glob metacharacters.
+// Characters coming from variables or escape sequences are always literals.
+type globPath []globRune
+
+func (g *globPath) writeLiteral(s string) {
+	for _, r := range s {
+		*g = append(*g, globRune{r: r})
+	}
+}
+
+func (g *globPath) writeMeta(r rune) {
+	*g = append(*g, globRune{r: r, meta: true})
+}
+
+func (g globPath) hasMeta() bool {
+	return slices.ContainsFunc(g, func(r globRune) bool { return r.meta })
+}
+
+func (g globPath) String() string {
+	var b strings.Builder
+	for _, r := range g {
+		b.WriteRune(r.r)
+	}
+	return b.String()
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == filepath.Separator
+}
+
+// Splits the path at its literal separators. An absolute path starts with an
+// empty segment, other empty segments are dropped.
+func (g globPath) segments() []globPath {
+	var segments []globPath
+	start := 0
+	for i, r := range g {
+		if !r.meta && isSeparator(r.r) {
+			if i > start || len(segments) == 0 {
+				segments = append(segments, g[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(g) {
+		segments = append(segments, g[start:])
+	}
+	return segments
+}
+
+func (g globPath) isDoubleStar() bool {
+	return len(g) == 2 && g[0].meta && g[0].r == '*' && g[1].meta && g[1].r == '*'
+}
+
+// Reports whether name matches the segment, where `*` matches any sequence
+// of characters and `?` any single character.
+func (g globPath) match(name string) bool {
+	return matchRunes(g, []rune(name))
+}
+
+func matchRunes(pattern globPath, name []rune) bool {
+	for len(pattern) > 0 {
+		head := pattern[0]
+		switch {
+		case head.meta && head.r == '*':
+			// collapse consecutive stars
+			for len(pattern) > 0 && pattern[0].meta && pattern[0].r == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchRunes(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		case head.meta && head.r == '?':
+			if len(name) == 0 {
+				return false
+			}
+		default:
+			if len(name) == 0 || name[0] != head.r {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
+func joinSegment(dir string, name string) string {
+	if dir == "" {
+		return name
+	}
+	if isSeparator(rune(dir[len(dir)-1])) {
+		return dir + name
+	}
+	return dir + string(filepath.Separator) + name
+}
+
+// Returns the existing paths matching the glob, sorted.
+//
+// `*` and `?` match within a single path segment, a `**` segment matches
+// any number of directories, including none.
+func (p *PathPatternEvaluator) expandGlob(glob globPath) ([]string, error) {
+	segments := glob.segments()
+	// leading literal segments are used as is
+	var base []string
+	i := 0
+	for ; i < len(segments) && !segments[i].hasMeta(); i++ {
+		base = append(base, segments[i].String())
+	}
+	candidates := []string{strings.Join(base, string(filepath.Separator))}
+	if len(base) == 1 && base[0] == "" {
+		candidates[0] = string(filepath.Separator)
+	}
+
+	for ; i < len(segments); i++ {
+		segment := segments[i]
+		var next []string
+		for _, dir := range candidates {
+			switch {
+			case segment.isDoubleStar():
+				next = append(next, p.walkDirs(dir)...)
+			case !segment.hasMeta():
+				next = append(next, joinSegment(dir, segment.String()))
+			default:
+				entries, err := p.readDir(dir)
+				if err != nil {
+					p.l.Debug("Skipping unreadable directory", "dir", dir, "error", err)
+					p.tracef(TraceRejected, "read %s: %s", dir, err)
+					continue
+				}
+				for _, entry := range entries {
+					if segment.match(entry.Name) {
+						next = append(next, joinSegment(dir, entry.Name))
+					}
+				}
+			}
+		}
+		candidates = next
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		candidate = filepath.Clean(candidate)
+		if slices.Contains(matches, candidate) {
+			continue
+		}
+		if err := p.statPath(candidate); err == nil {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no path matches %s", glob)
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+func (p *PathPatternEvaluator) readDir(dir string) ([]DirEntry, error) {
+	if dir == "" {
+		dir = "."
+	}
+	host, err := p.hostPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	return p.filesystem.ReadDir(host)
+}
+
+// Returns dir and all the directories below it. Symbolic links are not
+// followed.
+func (p *PathPatternEvaluator) walkDirs(dir string) []string {
+	dirs := []string{dir}
+	entries, err := p.readDir(dir)
+	if err != nil {
+		return dirs
+	}
+	for _, entry := range entries {
+		if entry.IsDir {
+			dirs = append(dirs, p.walkDirs(joinSegment(dir, entry.Name))...)
+		}
+	}
+	return dirs
+}
+
+// Reports whether the path name matches glob, written with the glob syntax of
+// patterns: `*` and `?` match within a segment and a `**` segment matches any
+// number of directories.
+func MatchGlob(glob string, name string) bool {
+	g, n := parseMatch(glob, name)
+	return matchSegments(g.segments(), n.segments())
+}
+
+// Reports whether a path below the directory name can match glob, that is
+// whether name matches the leading segments of glob. A leading `**`, e.g. in
+// `**/node_modules`, is not taken as matching the parents of what it
+// matches, or every directory would.
+func MatchGlobBelow(glob string, name string) bool {
+	g, n := parseMatch(glob, name)
+	return matchSegmentsBelow(g.segments(), n.segments(), false)
+}
+
+func parseMatch(glob string, name string) (globPath, globPath) {
+	var g, n globPath
+	for _, r := range glob {
+		if r == '*' || r == '?' {
+			g.writeMeta(r)
+		} else {
+			g.writeLiteral(string(r))
+		}
+	}
+	n.writeLiteral(filepath.Clean(name))
+	return g, n
+}
+
+// anchored is whether a segment of the glob matched already.
+func matchSegmentsBelow(glob []globPath, names []globPath, anchored bool) bool {
+	if len(names) == 0 {
+		return len(glob) > 0
+	}
+	if len(glob) == 0 {
+		return false
+	}
+	if glob[0].isDoubleStar() {
+		for i := 0; i <= len(names); i++ {
+			if i == len(names) && !anchored {
+				break
+			}
+			if matchSegmentsBelow(glob[1:], names[i:], anchored) {
+				return true
+			}
+		}
+		return false
+	}
+	return glob[0].match(names[0].String()) && matchSegmentsBelow(glob[1:], names[1:], true)
+}
+
+func matchSegments(glob []globPath, names []globPath) bool {
+	if len(glob) == 0 {
+		return len(names) == 0
+	}
+	if glob[0].isDoubleStar() {
+		for i := 0; i <= len(names); i++ {
+			if matchSegments(glob[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(names) > 0 && glob[0].match(names[0].String()) && matchSegments(glob[1:], names[1:])
+}
@@ -0,0 +1,67 @@
+package path
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Configures a PathPatternEvaluator, see NewPathPatternEvaluator.
+type Option func(*PathPatternEvaluator)
+
+// Logs the evaluation steps at debug level. Logs go to stderr by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(p *PathPatternEvaluator) {
+		p.l = l
+	}
+}
+
+// Evaluates the pattern against a filesystem other than the real one, e.g.
+// a mock in tests.
+func WithFileSystem(filesystem FileSystem) Option {
+	return func(p *PathPatternEvaluator) {
+		p.filesystem = filesystem
+	}
+}
+
+// Runs the options of either and all-of with the runtime, DefaultRuntime by
+// default.
+func WithRuntime(runtime Runtime) Option {
+	return func(p *PathPatternEvaluator) {
+		p.lifecycle = runtime
+	}
+}
+
+// Evaluates the pattern below root, e.g. a mounted disk: the filesystem is
+// accessed at root joined with the evaluated paths, and the returned paths
+// are prefixed with root.
+func WithRoot(root string) Option {
+	return func(p *PathPatternEvaluator) {
+		p.root = root
+	}
+}
+
+// Evaluates the variables of the pattern in the context, NewPathContext by
+// default.
+func WithContext(context *PathContext) Option {
+	return func(p *PathPatternEvaluator) {
+		p.context = context
+	}
+}
+
+func NewPathPatternEvaluator(pattern string, opts ...Option) *PathPatternEvaluator {
+	p := &PathPatternEvaluator{
+		pattern:    pattern,
+		filesystem: &RealFileSystem{},
+		lifecycle:  &DefaultRuntime{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.l == nil {
+		p.l = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	if p.context == nil {
+		p.context = NewPathContext()
+	}
+	return p
+}
@@ -0,0 +1,364 @@
+package path
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type SyntaxError struct {
+	Pattern string
+	// Byte offset of the error in Pattern
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error in pattern %q at position %d: %s", e.Pattern, e.Pos, e.Msg)
+}
+
+// Parses the pattern, see PathPattern for the syntax.
+func (p PathPattern) Parse() (Sequence, error) {
+	return Parse(string(p))
+}
+
+// Parses a pattern, see PathPattern for the syntax.
+func Parse(pattern string) (Sequence, error) {
+	p := &parser{input: pattern}
+	home := pattern == "~" || strings.HasPrefix(pattern, "~/")
+	if home {
+		p.pos = 1
+	}
+	seq, err := p.parseSequence("")
+	if err != nil {
+		return nil, err
+	}
+	if home {
+		seq = append(Sequence{&Variable{Pos: 0, Name: "home"}}, seq...)
+	}
+	return seq, nil
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorf(pos int, format string, args ...any) error {
+	return &SyntaxError{Pattern: p.input, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+// Returns the next byte, or 0 at the end of the input.
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func describe(b byte) string {
+	if b == 0 {
+		return "end of pattern"
+	}
+	return fmt.Sprintf("%q", b)
+}
+
+// Parses a sequence up to one of the terminators, which is not consumed,
+// or the end of the input.
+func (p *parser) parseSequence(terminators string) (Sequence, error) {
+	seq := Sequence{}
+	var lit strings.Builder
+	litPos := -1
+	flush := func() {
+		if litPos >= 0 {
+			seq = append(seq, &Literal{Pos: litPos, Value: lit.String()})
+			lit.Reset()
+			litPos = -1
+		}
+	}
+	writeLiteral := func(pos int, s string) {
+		if litPos < 0 {
+			litPos = pos
+		}
+		lit.WriteString(s)
+	}
+
+	for !p.eof() {
+		c := p.peek()
+		if strings.IndexByte(terminators, c) >= 0 {
+			break
+		}
+		start := p.pos
+		switch c {
+		case '\\':
+			if p.pos+1 >= len(p.input) {
+				return nil, p.errorf(start, "trailing backslash")
+			}
+			_, size := utf8.DecodeRuneInString(p.input[p.pos+1:])
+			writeLiteral(start, p.input[p.pos+1:p.pos+1+size])
+			p.pos += 1 + size
+		case '{':
+			flush()
+			node, err := p.parseBraces()
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, node)
+		case '[':
+			flush()
+			options, err := p.parseOptions("either", ']')
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, &Either{Pos: start, Options: options})
+		case '<':
+			flush()
+			options, err := p.parseOptions("all-of", '>')
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, &AllOf{Pos: start, Options: options})
+		case '*', '?':
+			flush()
+			seq = append(seq, &Wildcard{Pos: start, Char: rune(c)})
+			p.pos++
+		case '}', ']', '>':
+			return nil, p.errorf(start, "unexpected %q, escape it with a backslash to use it literally", c)
+		default:
+			_, size := utf8.DecodeRuneInString(p.input[p.pos:])
+			writeLiteral(start, p.input[p.pos:p.pos+size])
+			p.pos += size
+		}
+	}
+	flush()
+	return seq, nil
+}
+
+// Parses the options of an either or all-of, starting at the opening
+// bracket and ending after the closing one.
+func (p *parser) parseOptions(kind string, closing byte) ([]Sequence, error) {
+	start := p.pos
+	p.pos++
+	var options []Sequence
+	for {
+		optionPos := p.pos
+		option, err := p.parseSequence("," + string(closing))
+		if err != nil {
+			return nil, err
+		}
+		if len(option) == 0 {
+			return nil, p.errorf(optionPos, "empty option in %s", kind)
+		}
+		options = append(options, option)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case closing:
+			p.pos++
+			return options, nil
+		default:
+			return nil, p.errorf(start, "unterminated %s, expected %q", kind, closing)
+		}
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.'
+}
+
+func (p *parser) readName() string {
+	start := p.pos
+	for !p.eof() && isNameChar(p.peek()) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// Parses a variable or a placeholder, starting at the opening brace and
+// ending after the closing one.
+func (p *parser) parseBraces() (Node, error) {
+	start := p.pos
+	p.pos++
+	if strings.HasPrefix(p.input[p.pos:], "cmd:") {
+		return p.parseCommand(start)
+	}
+	if name := p.readName(); name != "" {
+		if p.peek() == '}' {
+			p.pos++
+			return &Variable{Pos: start, Name: name}, nil
+		}
+		if strings.HasPrefix(p.input[p.pos:], ":-") {
+			return p.parseVariableDefault(start, name)
+		}
+		if p.eof() {
+			return nil, p.errorf(start, "unterminated braces, expected '}'")
+		}
+	}
+	p.pos = start + 1
+	return p.parsePlaceholder(start)
+}
+
+// Parses the command of `{cmd:command}`, starting after the opening brace.
+// The command ends at the first unescaped `}`.
+func (p *parser) parseCommand(start int) (*Command, error) {
+	p.pos += len("cmd:")
+	var command strings.Builder
+	for {
+		switch c := p.peek(); {
+		case p.eof():
+			return nil, p.errorf(start, "unterminated command, expected '}'")
+		case c == '}':
+			p.pos++
+			if strings.TrimSpace(command.String()) == "" {
+				return nil, p.errorf(start, "empty command")
+			}
+			return &Command{Pos: start, Command: command.String()}, nil
+		case c == '\\':
+			if p.pos+1 >= len(p.input) {
+				return nil, p.errorf(p.pos, "trailing backslash")
+			}
+			_, size := utf8.DecodeRuneInString(p.input[p.pos+1:])
+			command.WriteString(p.input[p.pos+1 : p.pos+1+size])
+			p.pos += 1 + size
+		default:
+			command.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// Parses the default of `{name:-default}`, starting at the `:-`.
+func (p *parser) parseVariableDefault(start int, name string) (*Variable, error) {
+	p.pos += 2
+	value, err := p.parseSequence("}")
+	if err != nil {
+		return nil, err
+	}
+	if p.eof() {
+		return nil, p.errorf(start, "unterminated braces, expected '}'")
+	}
+	p.pos++
+	return &Variable{Pos: start, Name: name, Default: value, HasDefault: true}, nil
+}
+
+func (p *parser) parsePlaceholder(start int) (*Placeholder, error) {
+	placeholder := &Placeholder{Pos: start}
+	var previous *Condition
+	for {
+		branchPos := p.pos
+		condition, err := p.parseCondition(previous)
+		if err != nil {
+			return nil, err
+		}
+		if condition != nil {
+			value, err := p.parseSequence(",}")
+			if err != nil {
+				return nil, err
+			}
+			placeholder.Branches = append(placeholder.Branches, Branch{Condition: *condition, Value: value})
+			previous = condition
+		} else {
+			if previous == nil {
+				if p.eof() {
+					return nil, p.errorf(start, "unterminated braces, expected '}'")
+				}
+				return nil, p.errorf(branchPos, "expected a variable name or a condition")
+			}
+			if p.peek() == '!' && p.pos+1 < len(p.input) && p.input[p.pos+1] == '}' {
+				placeholder.Required = true
+				p.pos++
+			} else {
+				value, err := p.parseSequence(",}")
+				if err != nil {
+					return nil, err
+				}
+				placeholder.Default = value
+				placeholder.HasDefault = true
+			}
+			if p.peek() != '}' {
+				return nil, p.errorf(p.pos, "the default value must be the last option of a placeholder")
+			}
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return placeholder, nil
+		default:
+			return nil, p.errorf(start, "unterminated placeholder, expected '}'")
+		}
+	}
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (p *parser) readOperator() string {
+	for _, op := range operators {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func isOperandChar(c byte) bool {
+	return isNameChar(c) || c == '-' || c == '+'
+}
+
+// Reads a `{variable}` or a bare word.
+func (p *parser) readOperand() (Operand, bool) {
+	start := p.pos
+	if p.peek() == '{' {
+		p.pos++
+		if name := p.readName(); name != "" && p.peek() == '}' {
+			p.pos++
+			return Operand{Pos: start, Value: name, IsVariable: true}, true
+		}
+		p.pos = start
+		return Operand{}, false
+	}
+	for !p.eof() && isOperandChar(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		return Operand{}, false
+	}
+	return Operand{Pos: start, Value: p.input[start:p.pos]}, true
+}
+
+// Parses a condition and the colon following it. Returns nil without
+// consuming anything if the branch does not start with a condition, i.e.
+// it is the default value.
+func (p *parser) parseCondition(previous *Condition) (*Condition, error) {
+	start := p.pos
+	left, ok := p.readOperand()
+	if !ok {
+		return nil, nil
+	}
+	op := p.readOperator()
+	if op == "" {
+		if previous != nil && p.peek() == ':' {
+			p.pos++
+			return &Condition{Pos: start, Left: previous.Left, Op: previous.Op, Right: left, Short: true}, nil
+		}
+		p.pos = start
+		return nil, nil
+	}
+	// the left side is always a variable
+	left.IsVariable = true
+	right, ok := p.readOperand()
+	if !ok {
+		return nil, p.errorf(p.pos, "expected a value after %s, found %s", op, describe(p.peek()))
+	}
+	if p.peek() != ':' {
+		return nil, p.errorf(p.pos, "expected ':' after condition %s, found %s", p.input[start:p.pos], describe(p.peek()))
+	}
+	p.pos++
+	return &Condition{Pos: start, Left: left, Op: op, Right: right}, nil
+}
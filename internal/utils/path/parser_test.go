@@ -0,0 +1,184 @@
+package path
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVariablesAndLiterals(t *testing.T) {
+	ast, err := Parse("{env.HOME}/.cargo/bin")
+	assert.NoError(t, err)
+	assert.Equal(t, Sequence{
+		&Variable{Pos: 0, Name: "env.HOME"},
+		&Literal{Pos: 10, Value: "/.cargo/bin"},
+	}, ast)
+}
+
+func TestParseTilde(t *testing.T) {
+	ast, err := Parse("~/.cache")
+	assert.NoError(t, err)
+	assert.Equal(t, Sequence{
+		&Variable{Pos: 0, Name: "home"},
+		&Literal{Pos: 1, Value: "/.cache"},
+	}, ast)
+
+	// only a leading `~` naming a whole segment is expanded
+	for _, pattern := range []string{"~user/x", "/a/~/b", "\\~/x"} {
+		ast, err := Parse(pattern)
+		assert.NoError(t, err)
+		for _, n := range ast {
+			assert.IsType(t, &Literal{}, n, pattern)
+		}
+	}
+}
+
+func TestParseVariableDefault(t *testing.T) {
+	ast, err := Parse("{env.CARGO_HOME:-{home}/.cargo}/registry")
+	assert.NoError(t, err)
+	assert.Equal(t, Sequence{
+		&Variable{Pos: 0, Name: "env.CARGO_HOME", HasDefault: true, Default: Sequence{
+			&Variable{Pos: 17, Name: "home"},
+			&Literal{Pos: 23, Value: "/.cargo"},
+		}},
+		&Literal{Pos: 31, Value: "/registry"},
+	}, ast)
+}
+
+func TestParseCommand(t *testing.T) {
+	ast, err := Parse("{cmd:go env GOCACHE}/x")
+	assert.NoError(t, err)
+	assert.Equal(t, Sequence{
+		&Command{Pos: 0, Command: "go env GOCACHE"},
+		&Literal{Pos: 20, Value: "/x"},
+	}, ast)
+}
+
+func TestParseEitherAndAllOf(t *testing.T) {
+	ast, err := Parse("[{env.HOME}/.cargo,/opt/cargo]/<a,b\\,c>")
+	assert.NoError(t, err)
+	assert.Equal(t, Sequence{
+		&Either{Pos: 0, Options: []Sequence{
+			{&Variable{Pos: 1, Name: "env.HOME"}, &Literal{Pos: 11, Value: "/.cargo"}},
+			{&Literal{Pos: 19, Value: "/opt/cargo"}},
+		}},
+		&Literal{Pos: 30, Value: "/"},
+		&AllOf{Pos: 31, Options: []Sequence{
+			{&Literal{Pos: 32, Value: "a"}},
+			{&Literal{Pos: 34, Value: "b,c"}},
+		}},
+	}, ast)
+}
+
+func TestParsePlaceholder(t *testing.T) {
+	ast, err := Parse("{os==darwin:/opt/homebrew,linux:/home/linuxbrew,!}")
+	assert.NoError(t, err)
+	assert.Equal(t, Sequence{
+		&Placeholder{Pos: 0, Required: true, Branches: []Branch{
+			{
+				Condition: Condition{Pos: 1, Left: Operand{Pos: 1, Value: "os", IsVariable: true}, Op: "==", Right: Operand{Pos: 5, Value: "darwin"}},
+				Value:     Sequence{&Literal{Pos: 12, Value: "/opt/homebrew"}},
+			},
+			{
+				Condition: Condition{Pos: 26, Left: Operand{Pos: 1, Value: "os", IsVariable: true}, Op: "==", Right: Operand{Pos: 26, Value: "linux"}, Short: true},
+				Value:     Sequence{&Literal{Pos: 32, Value: "/home/linuxbrew"}},
+			},
+		}},
+	}, ast)
+}
+
+func TestParseGlobs(t *testing.T) {
+	ast, err := Parse("/a/**/b?\\*")
+	assert.NoError(t, err)
+	assert.Equal(t, Sequence{
+		&Literal{Pos: 0, Value: "/a/"},
+		&Wildcard{Pos: 3, Char: '*'},
+		&Wildcard{Pos: 4, Char: '*'},
+		&Literal{Pos: 5, Value: "/b"},
+		&Wildcard{Pos: 7, Char: '?'},
+		&Literal{Pos: 8, Value: "*"},
+	}, ast)
+}
+
+func TestParseErrors(t *testing.T) {
+	for pattern, pos := range map[string]int{
+		"{env.HOME":              0,
+		"[/a,/b":                 0,
+		"<a,b":                   0,
+		"/a/b}":                  4,
+		"/a]":                    2,
+		"[a,]":                   3,
+		"{}":                     1,
+		"{env.HOME/x}":           1,
+		"{os==:/a}":              5,
+		"{os==linux/a}":          10,
+		"{os==linux:/a,/b,/c}":   16,
+		"/a\\":                   2,
+		"{os==linux:[/a,/b}":     17,
+		"{os==linux:/a,darwin:}": -1,
+		"{env.A:-/a":             0,
+		"{env.A:-/a]}":           10,
+		"{cmd:go env":            0,
+		"{cmd: }":                0,
+	} {
+		_, err := Parse(pattern)
+		if pos < 0 {
+			assert.NoError(t, err, pattern)
+			continue
+		}
+		var syntaxErr *SyntaxError
+		if assert.ErrorAs(t, err, &syntaxErr, pattern) {
+			assert.Equal(t, pos, syntaxErr.Pos, "%s: %s", pattern, err)
+		}
+	}
+}
+
+var roundTripPatterns = []string{
+	"",
+	"/usr/local/bin",
+	"{env.HOME}/.cargo/bin",
+	"[{env.HOME}/.cargo/bin,{env.CARGO_HOME}/bin]",
+	"<{env.HOME}/.cargo,{env.CARGO_HOME}>/registry",
+	"{os==darwin:/opt/homebrew/bin/brew,linux:/home/linuxbrew/.linuxbrew/bin/brew}",
+	"{os==darwin:/a,linux:/b,!}",
+	"{arch!=arm64:/x,/default}",
+	"{env.HOME}/.gradle/caches/*/",
+	"/a/**/b?",
+	"/weird\\{name\\}/\\*",
+	"[/a\\,b,{os==linux:c\\,d,e\\:f}]",
+	"{home}/.cache",
+	"\\~user/x",
+	"{env.GOCACHE:-{xdg.cache}/go-build}",
+	"[{env.PUB_CACHE:-},/a]",
+	"{env.A:-[/b,{env.C:-/c\\}d}]}",
+	"{cmd:go env GOCACHE GOMODCACHE}",
+	"[{cmd:pip cache dir},{cmd:echo a\\}b}]",
+}
+
+func TestStringRoundTrips(t *testing.T) {
+	for _, pattern := range roundTripPatterns {
+		ast, err := Parse(pattern)
+		require.NoError(t, err, pattern)
+		assert.Equal(t, pattern, ast.String())
+	}
+}
+
+func FuzzParseString(f *testing.F) {
+	for _, pattern := range roundTripPatterns {
+		f.Add(pattern)
+	}
+	f.Add("{a==b:,\\!}")
+	f.Add("~/x")
+	f.Add("{x<1.2:[a,b],y:<c,d>,e=\\=f}")
+	f.Fuzz(func(t *testing.T, pattern string) {
+		ast, err := Parse(pattern)
+		if err != nil {
+			return
+		}
+		printed := ast.String()
+		reparsed, err := Parse(printed)
+		require.NoError(t, err, "%q printed as %q", pattern, printed)
+		assert.Equal(t, printed, reparsed.String(), "%q printed as %q", pattern, printed)
+	})
+}
@@ -7,13 +7,18 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // A pattern is a path-like string that can contains different patterns:
 //   - variables
 //   - placeholders
 //   - either
+//   - all of
+//   - globs
 //
 // # Variables
 //
@@ -25,7 +30,22 @@ import (
 //   - {os}
 //   - {arch}
 //   - {app_path}
+//   - {version}, the version of the app, see PathContext.ForApp
 //   - {env.HOME}, {env.PATH}, etc.
+//   - {home}, the home directory of the user, even when HOME is not set
+//   - {xdg.cache}, {xdg.data}, {xdg.config}, the XDG base directories, honouring XDG_*_HOME
+//   - {tmp}, the temporary directory
+//
+// `{cmd:<command>}` runs a command, e.g. `{cmd:go env GOCACHE}`, and evaluates to each line it
+// prints. The command is split on spaces and run without a shell, with a timeout, and only once
+// per PathContext. Commands are disabled in safe mode, see PathContext.SetSafeMode, and can be
+// limited to some commands, see PathContext.RestrictCommands.
+//
+// A `~` at the start of a pattern, alone or followed by a `/`, is a shorthand for {home}.
+//
+// Like in a shell, `{<variable>:-<default>}` evaluates to the default pattern when the variable
+// is not set, e.g. `{env.CARGO_HOME:-{home}/.cargo}`. The value of a variable with a default is
+// used as is, only the whole path has to exist.
 //
 // # Placeholders
 //
@@ -36,6 +56,8 @@ import (
 // > cond1 := expr1 op expr2
 //
 // where op is one of `==`, `!=`, `<`, `>`, `<=`, `>=`
+// Versions such as `1.22.19` or `2.0.0-rc.1` are compared like semver, other numbers numerically
+// and anything else as strings, e.g. `{version>=2:berry,v6}`.
 // where expr1 and expr2 are either:
 //   - a string
 //   - a number
@@ -44,9 +66,16 @@ import (
 // If no default is specified, and none of the conditions are met, the pattern is empty
 // In instead it is desired to throw an error, the default can be specified as follows:
 // {<cond1>:<value1>,<cond2>:<value2>,!}
-// If a path contains one of these characters: '{', '}', they have to be escaped with a backslash
-// Paths inside a placeholder are evaluated as a path pattern and should be surrounded by curly braces
-// if it contains any characters susceptible to being interpreted as a placeholder.
+// Values inside a placeholder are evaluated as path patterns. A `,` inside a value, or a `:`
+// inside the default, has to be escaped with a backslash.
+//
+// # Escaping
+//
+// The characters `\ { } [ ] < > * ?`, and a leading `~`, have to be escaped with a backslash to
+// be used literally. Version 1 manifests predate `< > * ?` and `~`, which they use literally and
+// which are escaped when they are migrated.
+// A pattern is parsed before being evaluated (see Parse), and malformed patterns, e.g. with
+// unbalanced brackets, fail with a *SyntaxError giving the position of the problem.
 //
 // Examples
 //
@@ -62,6 +91,29 @@ import (
 //
 // Examples:
 //   - [{env.HOME}/.cargo/bin, {env.CARGO_HOME}/bin]
+//
+// # All of
+//
+// All-of is like either but evaluates to every alternative that exists instead of the first one.
+// Alternatives resolving to the same real location, e.g. through a symbolic link, are only kept once.
+// All-of is surrounded by angle brackets and takes the form of
+// `<<path1>,<path2>,...>`
+//
+// Examples:
+//   - <{env.HOME}/.cargo,{env.CARGO_HOME}>/registry
+//
+// # Globs
+//
+// Path segments can contain the glob wildcards `*`, matching any sequence of characters,
+// and `?`, matching any single character. A segment consisting of `**` matches any number
+// of directories, including none. A pattern containing wildcards can resolve to several
+// existing paths, which are all returned by EvalAll. Wildcards coming from variables are
+// not expanded, and can be escaped with a backslash in the pattern.
+//
+// Examples:
+//   - {env.HOME}/.gradle/caches/*/
+//   - {env.HOME}/Library/Caches/JetBrains/*
+//   - {env.HOME}/.pub-cache/hosted/**/.cache
 type PathPattern string
 
 type PathContext struct {
@@ -69,21 +121,32 @@ type PathContext struct {
 	appPath     string
 	os          string
 	arch        string
+	dirs        userDirs
+
+	safeMode bool
+	// Prefixes of the commands that may run, nil allowing any command, see
+	// RestrictCommands
+	allowedCommands []string
+	commandTimeout  time.Duration
+	runCommand      commandRunner
+	commands        *commandCache
+
+	// Version of the app, set with ForApp
+	version string
 }
 
-func (p PathPattern) Eval(context *PathContext) (string, error) {
-	handler := slog.NewTextHandler(os.Stderr, nil)
-	logger := slog.New(handler)
-	eval := &PathPatternEvaluator{
-		pattern:    string(p),
-		root:       "",
-		context:    context,
-		l:          logger,
-		filesystem: &RealFileSystem{},
-		lifecycle:  &DefaultRuntime{},
-	}
+// Returns the first existing path matching the pattern.
+func (p PathPattern) Eval(context *PathContext, opts ...Option) (string, error) {
+	return p.evaluator(context, opts).Evaluate()
+}
 
-	return eval.Evaluate()
+// Returns all the existing paths matching the pattern.
+func (p PathPattern) EvalAll(context *PathContext, opts ...Option) ([]string, error) {
+	return p.evaluator(context, opts).EvaluateAll()
+}
+
+func (p PathPattern) evaluator(context *PathContext, opts []Option) *PathPatternEvaluator {
+	return NewPathPatternEvaluator(string(p), append([]Option{WithContext(context)}, opts...)...)
 }
 
 func NewPathContext() *PathContext {
@@ -98,6 +161,66 @@ func NewPathContext() *PathContext {
 		appPath:     "",
 		os:          runtime.GOOS,
 		arch:        runtime.GOARCH,
+		dirs:        currentUserDirs(),
+		commands:    &commandCache{},
+	}
+}
+
+// Returns a copy of the context for evaluating the caches of an app, where
+// `{app_path}` and `{version}` are set. Command outputs are shared with the
+// original context. version can be empty if it is not known.
+func (c *PathContext) ForApp(appPath string, version string) *PathContext {
+	return &PathContext{
+		environment:     c.environment,
+		appPath:         appPath,
+		os:              c.os,
+		arch:            c.arch,
+		dirs:            c.dirs,
+		safeMode:        c.safeMode,
+		allowedCommands: c.allowedCommands,
+		commandTimeout:  c.commandTimeout,
+		runCommand:      c.runCommand,
+		commands:        c.sharedCommands(),
+		version:         version,
+	}
+}
+
+// Returns a context describing another system than the current one, e.g. a
+// mounted disk image evaluated with WithRoot. The directories of the user
+// are derived from home and goos, or from XDG_*_HOME in environment.
+// Empty goos and goarch default to the current ones. Commands are disabled,
+// as the tools of the other system cannot be run.
+func NewSyntheticPathContext(home string, goos string, goarch string, environment map[string]string) *PathContext {
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	env := make(map[string]string, len(environment)+1)
+	for k, v := range environment {
+		env[k] = v
+	}
+	if home != "" && env["HOME"] == "" {
+		env["HOME"] = home
+	}
+	dirs := defaultUserDirs(home, goos)
+	for name, dir := range map[string]*string{
+		"XDG_CACHE_HOME":  &dirs.xdgCache,
+		"XDG_DATA_HOME":   &dirs.xdgData,
+		"XDG_CONFIG_HOME": &dirs.xdgConfig,
+	} {
+		if value := env[name]; value != "" {
+			*dir = value
+		}
+	}
+	return &PathContext{
+		environment: env,
+		os:          goos,
+		arch:        goarch,
+		dirs:        dirs,
+		safeMode:    true,
+		commands:    &commandCache{},
 	}
 }
 
@@ -107,6 +230,17 @@ func (c *PathContext) GetEnv(name string) string {
 
 type FileSystem interface {
 	Stat(string) (int, error)
+	ReadDir(string) ([]DirEntry, error)
+	// Returns the path with symbolic links resolved
+	RealPath(string) (string, error)
+	// Returns the target of a symbolic link, or an error if the file is not
+	// one
+	Readlink(string) (string, error)
+}
+
+type DirEntry struct {
+	Name  string
+	IsDir bool
 }
 
 type RealFileSystem struct {
@@ -121,6 +255,26 @@ func (f *RealFileSystem) Stat(name string) (int, error) {
 	}
 }
 
+func (f *RealFileSystem) ReadDir(name string) ([]DirEntry, error) {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]DirEntry, len(entries))
+	for i, entry := range entries {
+		result[i] = DirEntry{Name: entry.Name(), IsDir: entry.IsDir()}
+	}
+	return result, nil
+}
+
+func (f *RealFileSystem) RealPath(name string) (string, error) {
+	return filepath.EvalSymlinks(name)
+}
+
+func (f *RealFileSystem) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
 type PathPatternEvaluator struct {
 	pattern    string
 	root       string
@@ -128,235 +282,501 @@ type PathPatternEvaluator struct {
 	l          *slog.Logger
 	filesystem FileSystem
 	lifecycle  Runtime
+	// Current step when explaining the evaluation
+	trace *Trace
 }
 
-func NewPathPatternEvaluator(pattern string) *PathPatternEvaluator {
-	opts := slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug}
-	handler := slog.NewTextHandler(os.Stderr, &opts)
-	logger := slog.New(handler)
-	return &PathPatternEvaluator{
-		pattern:    pattern,
-		root:       "",
-		context:    NewPathContext(),
-		l:          logger,
-		filesystem: &RealFileSystem{},
-		lifecycle:  &DefaultRuntime{},
+// Returns the first existing path matching the pattern.
+func (p *PathPatternEvaluator) Evaluate() (string, error) {
+	results, err := p.EvaluateAll()
+	if err != nil {
+		return "", err
 	}
+	return results[0], nil
 }
 
-func (p *PathPatternEvaluator) Evaluate() (string, error) {
+// Returns all the existing paths matching the pattern, there is more than one
+// only if the pattern contains globs or all-of.
+func (p *PathPatternEvaluator) EvaluateAll() ([]string, error) {
 	p.l.Debug("Evaluating pattern", "pattern", p.pattern)
-	result, err := p.evaluateInternal(p.pattern)
+	ast, err := Parse(p.pattern)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	paths, err := p.evaluatePaths(ast)
+	if err != nil {
+		return nil, err
 	}
-	return filepath.Clean(result), nil
+	if p.root != "" {
+		for i, path := range paths {
+			if paths[i], err = p.hostPath(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return paths, nil
 }
 
-func (p *PathPatternEvaluator) evaluateInternal(pattern string) (string, error) {
-	var result strings.Builder
+// Maximum number of symbolic links followed when resolving a path below the
+// root, like the limit of Linux.
+const maxSymlinks = 40
 
-	for len(pattern) > 0 {
-		switch pattern[0] {
-		case '{':
-			p.l.Debug("Evaluating variable", "pattern", pattern)
-			closingBrace := findClosingBrace(pattern)
-			if closingBrace == -1 {
-				return "", errors.New("unmatched opening brace")
+// Returns the path on this system of a path of the evaluated system, i.e.
+// the path below the root. Symbolic links are resolved within the root, as
+// they would be on the system mounted there, and paths leaving the root are
+// refused.
+func (p *PathPatternEvaluator) hostPath(path string) (string, error) {
+	if p.root == "" {
+		return path, nil
+	}
+	var resolved []string
+	pending := strings.FieldsFunc(path, isSeparator)
+	links := 0
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		switch name {
+		case ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return "", fmt.Errorf("path %s leaves the root %s", path, p.root)
 			}
-			subPattern := pattern[1:closingBrace]
-			evaluated, err := p.evaluateVariable(subPattern)
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		target, err := p.filesystem.Readlink(filepath.Join(p.root, filepath.Join(append(resolved, name)...)))
+		if err != nil {
+			// not a link, or missing which is reported when it is used
+			resolved = append(resolved, name)
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("too many symbolic links in %s", path)
+		}
+		if strings.HasPrefix(target, "/") || filepath.IsAbs(target) {
+			// absolute links point into the mounted system
+			resolved = nil
+		}
+		pending = append(strings.FieldsFunc(target, isSeparator), pending...)
+	}
+	return filepath.Join(p.root, filepath.Join(resolved...)), nil
+}
+
+// Checks that a path of the evaluated system exists.
+func (p *PathPatternEvaluator) statPath(path string) error {
+	host, err := p.hostPath(path)
+	if err != nil {
+		p.tracef(TraceRejected, "%s", err)
+		return err
+	}
+	return p.stat(host)
+}
+
+// Evaluates a sequence to the existing paths it matches.
+func (p *PathPatternEvaluator) evaluatePaths(seq Sequence) ([]string, error) {
+	results, err := p.evaluateSequence(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	var lastErr error
+	for _, result := range results {
+		if result.hasMeta() {
+			p.l.Debug("Expanding glob", "glob", result)
+			step := p.tracef(TraceInfo, "glob %s", result)
+			matches, err := p.tracing(step).expandGlob(result)
+			step.done(err, "matched %s", matches)
 			if err != nil {
-				return "", fmt.Errorf("error evaluating variable %s (%s)", subPattern, err)
+				lastErr = err
+				continue
 			}
-			evaluated.WriteToBuilder(&result)
-			pattern = pattern[closingBrace+1:]
-
-			p.l.Debug("Evaluated variable", "pattern", pattern, "evaluated", evaluated)
-		case '[':
-			p.l.Debug("Evaluating either", "pattern", pattern)
-			closingBracket := findClosingBracket(pattern)
-			if closingBracket == -1 {
-				return "", errors.New("unmatched opening bracket")
+			paths = append(paths, matches...)
+			continue
+		}
+		out := result.String()
+		// check if the result is a valid path
+		if err := p.statPath(out); err != nil {
+			lastErr = fmt.Errorf("invalid path %s (%s)", out, err)
+			continue
+		}
+		paths = append(paths, out)
+	}
+	if len(paths) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("pattern %s is empty", seq)
+		}
+		return nil, lastErr
+	}
+	for i, path := range paths {
+		paths[i] = filepath.Clean(path)
+	}
+	return p.dedupe(paths), nil
+}
+
+// Evaluates a sequence to the paths it describes, without checking that they
+// exist. All-of and placeholders can make a sequence evaluate to several
+// paths, which are built side by side.
+func (p *PathPatternEvaluator) evaluateSequence(seq Sequence) ([]globPath, error) {
+	results := []globPath{nil}
+	for _, node := range seq {
+		switch n := node.(type) {
+		case *Literal:
+			results = appendToAll(results, ExistingPath(n.Value))
+		case *Wildcard:
+			for i := range results {
+				results[i].writeMeta(n.Char)
+			}
+		case *Variable:
+			p.l.Debug("Evaluating variable", "variable", n.Name)
+			step := p.tracef(TraceInfo, "variable %s", n.Name)
+			evaluated, err := p.tracing(step).evaluateVariable(n)
+			step.done(err, "= %s", evaluated)
+			if err != nil {
+				return nil, fmt.Errorf("error evaluating variable %s (%w)", n.Name, err)
+			}
+			p.l.Debug("Evaluated variable", "variable", n.Name, "evaluated", evaluated)
+			results = appendPathsToAll(results, evaluated)
+		case *Command:
+			p.l.Debug("Running command", "command", n.Command)
+			step := p.tracef(TraceInfo, "command %s", n.Command)
+			lines, err := p.context.RunCommand(n.Command)
+			step.done(err, "printed %q", lines)
+			if err != nil {
+				return nil, fmt.Errorf("error running command %s (%w)", n.Command, err)
 			}
-			subPattern := pattern[1:closingBracket]
-			evaluated, err := p.evaluateEither(subPattern)
+			p.l.Debug("Ran command", "command", n.Command, "output", lines)
+			evaluated := make([]ExistingPath, len(lines))
+			for i, line := range lines {
+				evaluated[i] = ExistingPath(line)
+			}
+			results = appendToAll(results, evaluated...)
+		case *Placeholder:
+			p.l.Debug("Evaluating placeholder", "placeholder", n)
+			step := p.tracef(TraceInfo, "placeholder %s", n)
+			evaluated, err := p.tracing(step).evaluatePlaceholder(n)
+			step.done(err, "= %s", evaluated)
 			if err != nil {
-				return "", err
+				return nil, fmt.Errorf("error evaluating placeholder %s (%w)", n, err)
 			}
-			p.l.Debug("Evaluated either", "pattern", pattern, "evaluated", evaluated)
-
-			evaluated.WriteToBuilder(&result)
-			pattern = pattern[closingBracket+1:]
-		case '\\':
-			p.l.Debug("Evaluating escape sequence", "pattern", pattern)
-			if len(pattern) > 1 {
-				result.WriteByte(pattern[1])
-				pattern = pattern[2:]
-			} else {
-				return "", errors.New("invalid escape sequence")
+			results = appendPathsToAll(results, evaluated)
+		case *Either:
+			p.l.Debug("Evaluating either", "either", n)
+			step := p.tracef(TraceInfo, "either %s", n)
+			evaluated, err := p.tracing(step).evaluateEither(n)
+			step.done(err, "= %s", evaluated)
+			if err != nil {
+				return nil, err
 			}
+			p.l.Debug("Evaluated either", "either", n, "evaluated", evaluated)
+			results = appendToAll(results, evaluated...)
+		case *AllOf:
+			p.l.Debug("Evaluating all-of", "all-of", n)
+			step := p.tracef(TraceInfo, "all-of %s", n)
+			evaluated, err := p.tracing(step).evaluateAllOf(n)
+			step.done(err, "= %s", evaluated)
+			if err != nil {
+				return nil, err
+			}
+			p.l.Debug("Evaluated all-of", "all-of", n, "evaluated", evaluated)
+			results = appendToAll(results, evaluated...)
 		default:
-			result.WriteByte(pattern[0])
-			pattern = pattern[1:]
+			return nil, fmt.Errorf("unknown pattern node %T", node)
 		}
 	}
+	return results, nil
+}
+
+// Returns every combination of a path of results followed by one of suffixes.
+func appendToAll(results []globPath, suffixes ...ExistingPath) []globPath {
+	globs := make([]globPath, len(suffixes))
+	for i, suffix := range suffixes {
+		globs[i].writeLiteral(string(suffix))
+	}
+	return appendPathsToAll(results, globs)
+}
+
+// Returns every combination of a path of results followed by one of suffixes.
+func appendPathsToAll(results []globPath, suffixes []globPath) []globPath {
+	if len(suffixes) == 1 {
+		for i := range results {
+			results[i] = append(results[i], suffixes[0]...)
+		}
+		return results
+	}
+	combined := make([]globPath, 0, len(results)*len(suffixes))
+	for _, result := range results {
+		for _, suffix := range suffixes {
+			combined = append(combined, append(slices.Clone(result), suffix...))
+		}
+	}
+	return combined
+}
 
-	out := result.String()
-	// check if the result is a valid path
-	if _, err := p.filesystem.Stat(out); err != nil {
-		return "", fmt.Errorf("invalid path %s (%s)", out, err)
+// Removes the paths resolving to the same real location as a previous path.
+func (p *PathPatternEvaluator) dedupe(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	deduped := paths[:0]
+	for _, path := range paths {
+		var real string
+		var err error
+		if p.root != "" {
+			// already resolved within the root
+			real, err = p.hostPath(path)
+		} else {
+			real, err = p.filesystem.RealPath(path)
+		}
+		if err != nil {
+			real = path
+		}
+		if seen[real] {
+			p.l.Debug("Skipping duplicate path", "path", path, "real", real)
+			continue
+		}
+		seen[real] = true
+		deduped = append(deduped, path)
 	}
-	return out, nil
+	return deduped
 }
 
-func (p *PathPatternEvaluator) evaluateVariable(variable string) (ExistingPath, error) {
-	// Implement variable evaluation logic here
-	// This should handle environment variables, app-specific variables, and placeholders
-	var result string
+// Returned when a known variable has no value, in which case its default is
+// used if it has one.
+var ErrUnset = errors.New("not set")
+
+// Returns the value of a variable, and whether it is a path that must exist.
+func (p *PathPatternEvaluator) variableValue(variable string) (string, bool, error) {
 	if strings.HasPrefix(variable, "env.") {
 		envVar := variable[4:]
 		value := p.context.GetEnv(envVar)
 		if value == "" {
-			return "", fmt.Errorf("environment variable %s not found", envVar)
+			return "", false, fmt.Errorf("environment variable %s %w", envVar, ErrUnset)
 		}
-		result = value
-	} else {
-		switch variable {
-		case "os":
-			result = p.context.os
-		case "arch":
-			result = p.context.arch
-		case "app_path":
-			if p.context.appPath == "" {
-				return "", fmt.Errorf("app_path not set")
-			} else {
-				result = p.context.appPath
-			}
-		default:
-			return "", fmt.Errorf("unknown variable %s", variable)
+		return value, true, nil
+	}
+	if value, ok, err := p.context.userDir(variable); ok {
+		return value, true, err
+	}
+	switch variable {
+	case "os":
+		return p.context.os, false, nil
+	case "arch":
+		return p.context.arch, false, nil
+	case "version":
+		if p.context.version == "" {
+			return "", false, fmt.Errorf("version %w", ErrUnset)
 		}
+		return p.context.version, false, nil
+	case "app_path":
+		if p.context.appPath == "" {
+			return "", false, fmt.Errorf("app_path %w", ErrUnset)
+		}
+		return p.context.appPath, true, nil
+	default:
+		return "", false, fmt.Errorf("unknown variable %s", variable)
 	}
+}
 
-	return p.Exists(result)
+func (p *PathPatternEvaluator) evaluateVariable(variable *Variable) ([]globPath, error) {
+	value, isPath, err := p.variableValue(variable.Name)
+	if variable.HasDefault {
+		if errors.Is(err, ErrUnset) {
+			p.l.Debug("Using default of unset variable", "variable", variable.Name)
+			p.tracef(TraceInfo, "not set, using the default %s", variable.Default)
+			return p.evaluateSequence(variable.Default)
+		}
+		// like in a shell, the value is used as is, whether it exists or
+		// not is only checked for the whole path
+		isPath = false
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isPath {
+		existing, err := p.Exists(value)
+		if err != nil {
+			return nil, err
+		}
+		value = string(existing)
+	}
+	return appendToAll([]globPath{nil}, ExistingPath(value)), nil
 }
 
 type ExistingPath string
 
+// Checks that subpath exists below the root.
 func (p *PathPatternEvaluator) Exists(subpath string) (ExistingPath, error) {
 	if !p.lifecycle.AllowedToRun() {
 		fmt.Println("PathPatternEvaluator.Exists(): lifecycle manager killed us :(")
 		return "", errors.New("lifecycle manager killed us :(")
 	}
-	fullPath := filepath.Join(p.root, subpath)
-	if _, err := p.filesystem.Stat(fullPath); err != nil {
-		return "", fmt.Errorf("path %s does not exist (%s)", fullPath, err)
+	if err := p.statPath(subpath); err != nil {
+		return "", fmt.Errorf("path %s does not exist (%s)", filepath.Join(p.root, subpath), err)
 	}
-	return ExistingPath(fullPath), nil
+	return ExistingPath(subpath), nil
 }
 
 func (p *ExistingPath) WriteToBuilder(builder *strings.Builder) {
 	builder.WriteString(string(*p))
 }
 
-type EvalEitherFunc func(string) (ExistingPath, error)
+// Evaluates the value of the first branch whose condition holds.
+func (p *PathPatternEvaluator) evaluatePlaceholder(placeholder *Placeholder) ([]globPath, error) {
+	for _, branch := range placeholder.Branches {
+		holds, err := p.evaluateCondition(branch.Condition)
+		if err != nil {
+			return nil, err
+		}
+		p.l.Debug("Evaluated condition", "condition", branch.Condition, "holds", holds)
+		if holds {
+			return p.evaluateSequence(branch.Value)
+		}
+	}
+	switch {
+	case placeholder.HasDefault:
+		p.tracef(TraceInfo, "using the default %s", placeholder.Default)
+		return p.evaluateSequence(placeholder.Default)
+	case placeholder.Required:
+		return nil, errors.New("no condition holds")
+	default:
+		// the placeholder is empty
+		return []globPath{nil}, nil
+	}
+}
+
+func (p *PathPatternEvaluator) operandValue(operand Operand) (string, error) {
+	if !operand.IsVariable {
+		return operand.Value, nil
+	}
+	value, _, err := p.variableValue(operand.Value)
+	return value, err
+}
 
-func (p *PathPatternEvaluator) evaluateEither(either string) (ExistingPath, error) {
-	options, err := SplitCommaSeparatedString(either)
+func (p *PathPatternEvaluator) evaluateCondition(condition Condition) (bool, error) {
+	left, err := p.operandValue(condition.Left)
 	if err != nil {
-		return "", err
+		return false, err
+	}
+	right, err := p.operandValue(condition.Right)
+	if err != nil {
+		return false, err
+	}
+	holds, err := compare(left, condition.Op, right)
+	if err != nil {
+		return false, err
+	}
+	if holds {
+		p.tracef(TraceAccepted, "condition %s holds (%s %s %s)", condition, left, condition.Op, right)
+	} else {
+		p.tracef(TraceRejected, "condition %s does not hold (%s %s %s)", condition, left, condition.Op, right)
+	}
+	return holds, nil
+}
+
+// Compares like semver when both sides are versions, which includes integers
+// and decimals like 3.5 < 3.10, numerically when both sides are other numbers
+// like -2 or 1e3, and as strings otherwise.
+func compare(left string, op string, right string) (bool, error) {
+	cmp := strings.Compare(left, right)
+	lv, lok := parseVersion(left)
+	rv, rok := parseVersion(right)
+	lf, lerr := strconv.ParseFloat(left, 64)
+	rf, rerr := strconv.ParseFloat(right, 64)
+	switch {
+	case lok && rok:
+		cmp = compareVersions(lv, rv)
+	case lerr == nil && rerr == nil:
+		cmp = cmpFloat(lf, rf)
+	}
+	switch op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case ">":
+		return cmp > 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	default:
+		return false, fmt.Errorf("unknown operator %s", op)
+	}
+}
+
+func cmpFloat(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
 	}
-	p.l.Debug("Split either", "either", either, "options", options)
-	Task := func(_ int, opt string) (ExistingPath, error) {
-		subEvaluator := &PathPatternEvaluator{
-			pattern:    opt,
-			root:       p.root,
-			context:    p.context,
-			l:          p.l,
-			filesystem: p.filesystem,
-			lifecycle:  p.lifecycle,
+}
+
+type EvalEitherFunc func(Sequence) ([]ExistingPath, error)
+
+// Evaluates an option of an either or all-of.
+// Steps are recorded under the corresponding trace, if any.
+func (p *PathPatternEvaluator) optionTask(kind string, traces []*Trace) func(int, Sequence) ([]ExistingPath, error) {
+	return func(i int, opt Sequence) ([]ExistingPath, error) {
+		p.l.Debug("Evaluating "+kind+" option", "option", opt)
+		var step *Trace
+		if traces != nil {
+			step = traces[i]
 		}
-		p.l.Debug("Evaluating either option", "option", opt)
-		if evaluated, err := subEvaluator.Evaluate(); err == nil {
-			p.l.Debug("Found valid path in either", "path", evaluated)
-			return ExistingPath(evaluated), nil
-		} else {
-			p.l.Debug("Skipping invalid path in either", "option", opt, "path", evaluated)
-			return "", err
+		evaluated, err := p.tracing(step).evaluatePaths(opt)
+		step.done(err, "= %s", evaluated)
+		if err != nil {
+			p.l.Debug("Skipping invalid path in "+kind, "option", opt, "error", err)
+			return nil, err
+		}
+		p.l.Debug("Found valid path in "+kind, "paths", evaluated)
+		paths := make([]ExistingPath, len(evaluated))
+		for i, path := range evaluated {
+			paths[i] = ExistingPath(path)
 		}
+		return paths, nil
 	}
-	res := p.lifecycle.RunAll(Task, options)
-	// p.l.Debug("Found results", "results", res)
-	return FirstResult(res)
 }
 
-func findClosingBrace(s string) int {
-	depth := 0
-	for i, ch := range s {
-		if ch == '{' {
-			depth++
-		} else if ch == '}' {
-			depth--
-			if depth == 0 {
-				return i
-			}
-		}
+// Creates the steps of the options up front, as they may be evaluated
+// concurrently.
+func (p *PathPatternEvaluator) optionTraces(options []Sequence) []*Trace {
+	if p.trace == nil {
+		return nil
 	}
-	return -1
+	traces := make([]*Trace, len(options))
+	for i, option := range options {
+		traces[i] = p.tracef(TraceInfo, "option %s", option)
+	}
+	return traces
 }
 
-func findClosingBracket(s string) int {
-	depth := 0
-	for i, ch := range s {
-		if ch == '[' {
-			depth++
-		} else if ch == ']' {
-			depth--
-			if depth == 0 {
-				return i
-			}
-		}
+func (p *PathPatternEvaluator) evaluateEither(either *Either) ([]ExistingPath, error) {
+	res := p.lifecycle.RunAll(p.optionTask("either", p.optionTraces(either.Options)), either.Options)
+	return FirstResult(res)
+}
+
+func (p *PathPatternEvaluator) evaluateAllOf(allOf *AllOf) ([]ExistingPath, error) {
+	res := p.lifecycle.RunAll(p.optionTask("all-of", p.optionTraces(allOf.Options)), allOf.Options)
+	found, err := AllResults(res)
+	if err != nil {
+		return nil, err
 	}
-	return -1
-}
-
-func SplitCommaSeparatedString(s string) ([]string, error) {
-	// We need to take in account that fact that commas could be escaed or nested in another pattern
-	// For example:
-	//   [/path1,{env.HOME}/[path2,path3],/path4]
-	// should be evaluated as:
-	//   /path1
-	//   {env.HOME}/path2
-	//   {env.HOME}/path3
-	//   /path4
-	var result []string
-	i := 0
-	chunk_start := 0
-	for ; i < len(s); i++ {
-		switch s[i] {
-		case '\\':
-			i++
-		case ',':
-			result = append(result, s[chunk_start:i])
-			i++
-			chunk_start = i
-		case '[':
-			closingBracket := findClosingBracket(s[i:])
-			if closingBracket == -1 {
-				return nil, errors.New("unmatched opening bracket")
-			}
-			i += closingBracket + 1
-		case '{':
-			closingBrace := findClosingBrace(s[i:])
-			if closingBrace == -1 {
-				return nil, errors.New("unmatched opening brace")
-			}
-			i += closingBrace + 1
+	var paths []string
+	for _, f := range found {
+		for _, path := range f {
+			paths = append(paths, string(path))
 		}
 	}
-	rest := s[chunk_start:]
-	if len(rest) > 0 {
-		result = append(result, rest)
+	deduped := p.dedupe(paths)
+	result := make([]ExistingPath, len(deduped))
+	for i, path := range deduped {
+		result[i] = ExistingPath(path)
 	}
 	return result, nil
 }
@@ -1,11 +1,18 @@
 package path
 
 import (
+	"context"
+	"errors"
 	"log"
 	"log/slog"
 	"os"
+	"os/exec"
+	"os/user"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -14,7 +21,9 @@ type MockFileSystem struct {
 	FileSystem
 
 	filesystem map[string]int
-	requested  []string
+	// symbolic links, from link to target
+	links     map[string]string
+	requested []string
 }
 
 func (f *MockFileSystem) Stat(name string) (int, error) {
@@ -31,6 +40,44 @@ func (f *MockFileSystem) Stat(name string) (int, error) {
 	return 0, os.ErrNotExist
 }
 
+func (f *MockFileSystem) ReadDir(name string) ([]DirEntry, error) {
+	name = filepath.Clean(name)
+	if _, ok := f.filesystem[name]; !ok {
+		return nil, os.ErrNotExist
+	}
+	var entries []DirEntry
+	for path := range f.filesystem {
+		if path != name && filepath.Dir(path) == name {
+			entries = append(entries, DirEntry{Name: filepath.Base(path), IsDir: f.isDir(path)})
+		}
+	}
+	return entries, nil
+}
+
+func (f *MockFileSystem) RealPath(name string) (string, error) {
+	name = filepath.Clean(name)
+	if target, ok := f.links[name]; ok {
+		return target, nil
+	}
+	return name, nil
+}
+
+func (f *MockFileSystem) Readlink(name string) (string, error) {
+	if target, ok := f.links[filepath.Clean(name)]; ok {
+		return target, nil
+	}
+	return "", os.ErrInvalid
+}
+
+func (f *MockFileSystem) isDir(name string) bool {
+	for path := range f.filesystem {
+		if path != name && filepath.Dir(path) == name {
+			return true
+		}
+	}
+	return false
+}
+
 func Logger() *slog.Logger {
 	opts := slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug}
 	handler := slog.NewTextHandler(os.Stderr, &opts)
@@ -100,3 +147,562 @@ func MockFileSystemMap(files ...string) map[string]int {
 	}
 	return m
 }
+
+func GlobEvaluator(pattern string, files ...string) *PathPatternEvaluator {
+	return NewPathPatternEvaluator(pattern,
+		WithContext(&PathContext{os: "linux", arch: "amd64", environment: map[string]string{
+			"HOME": "/home/gaetan",
+		}}),
+		WithLogger(Logger()),
+		WithFileSystem(&MockFileSystem{
+			filesystem: MockFileSystemMap(files...),
+		}),
+	)
+}
+
+func TestEvaluateGlobStar(t *testing.T) {
+	evaluator := GlobEvaluator("{env.HOME}/.gradle/caches/*/",
+		"/home/gaetan/.gradle/caches/7.6/file",
+		"/home/gaetan/.gradle/caches/8.5/file",
+		"/home/gaetan/.gradle/wrapper/dists",
+	)
+	results, err := evaluator.EvaluateAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/home/gaetan/.gradle/caches/7.6", "/home/gaetan/.gradle/caches/8.5"}, results)
+}
+
+func TestEvaluateGlobQuestionMark(t *testing.T) {
+	evaluator := GlobEvaluator("/opt/jdk-??",
+		"/opt/jdk-17",
+		"/opt/jdk-21",
+		"/opt/jdk-8",
+	)
+	results, err := evaluator.EvaluateAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/opt/jdk-17", "/opt/jdk-21"}, results)
+}
+
+func TestEvaluateGlobDoubleStar(t *testing.T) {
+	evaluator := GlobEvaluator("{env.HOME}/.pub-cache/hosted/**/.cache",
+		"/home/gaetan/.pub-cache/hosted/.cache",
+		"/home/gaetan/.pub-cache/hosted/pub.dev/.cache",
+		"/home/gaetan/.pub-cache/hosted/pub.dev/http/.cache",
+		"/home/gaetan/.pub-cache/hosted/pub.dev/http/lib",
+	)
+	results, err := evaluator.EvaluateAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/home/gaetan/.pub-cache/hosted/.cache",
+		"/home/gaetan/.pub-cache/hosted/pub.dev/.cache",
+		"/home/gaetan/.pub-cache/hosted/pub.dev/http/.cache",
+	}, results)
+}
+
+func TestEvaluateGlobNoMatch(t *testing.T) {
+	evaluator := GlobEvaluator("{env.HOME}/.gradle/caches/*", "/home/gaetan/.gradle")
+	_, err := evaluator.EvaluateAll()
+	assert.Error(t, err)
+}
+
+func TestEvaluateEscapedGlob(t *testing.T) {
+	evaluator := GlobEvaluator("/weird/\\*", "/weird/*", "/weird/other")
+	results, err := evaluator.EvaluateAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/weird/*"}, results)
+}
+
+func TestMatchGlob(t *testing.T) {
+	for glob, names := range map[string][]string{
+		"/home/*/.gradle/caches":     {"/home/gaetan/.gradle/caches", "/home/gaetan/.gradle/caches/"},
+		"/home/gaetan/.gradle/**":    {"/home/gaetan/.gradle", "/home/gaetan/.gradle/caches/modules-2"},
+		"**/node_modules":            {"/home/gaetan/src/app/node_modules"},
+		"/tmp/go-build??":            {"/tmp/go-build42"},
+		"/home/gaetan/**/caches/*.d": {"/home/gaetan/caches/a.d", "/home/gaetan/x/y/caches/b.d"},
+	} {
+		for _, name := range names {
+			assert.True(t, MatchGlob(glob, name), "%s %s", glob, name)
+		}
+	}
+	for glob, name := range map[string]string{
+		"/home/*/.gradle":         "/home/gaetan/src/.gradle",
+		"/home/gaetan/.gradle/*":  "/home/gaetan/.gradle",
+		"/tmp/go-build?":          "/tmp/go-build42",
+		"node_modules":            "/home/gaetan/node_modules",
+		"/home/gaetan/.gradle/**": "/home/gaetan/.m2",
+	} {
+		assert.False(t, MatchGlob(glob, name), "%s %s", glob, name)
+	}
+}
+
+func TestMatchGlobBelow(t *testing.T) {
+	for glob, names := range map[string][]string{
+		"/home/*/.gradle/caches/modules-2": {"/home/gaetan/.gradle/caches", "/home/gaetan", "/"},
+		"/home/gaetan/**/caches":           {"/home/gaetan", "/home/gaetan/src/app"},
+		"/home/gaetan/.gradle/**":          {"/home/gaetan/.gradle"},
+	} {
+		for _, name := range names {
+			assert.True(t, MatchGlobBelow(glob, name), "%s %s", glob, name)
+		}
+	}
+	for glob, name := range map[string]string{
+		"/home/*/.gradle/caches/modules-2": "/home/gaetan/.m2",
+		"/home/gaetan/.gradle":             "/home/gaetan/.gradle",
+		"/home/gaetan/.gradle/caches":      "/home/gaetan/.gradle/caches/modules-2",
+		"**/node_modules":                  "/home/gaetan/.npm",
+	} {
+		assert.False(t, MatchGlobBelow(glob, name), "%s %s", glob, name)
+	}
+}
+
+func TestEvaluateAllOf(t *testing.T) {
+	evaluator := GlobEvaluator("<{env.HOME}/.cargo,{env.CARGO_HOME},/nope>/registry",
+		"/home/gaetan/.cargo/registry",
+		"/opt/cargo/registry",
+	)
+	evaluator.context.environment["CARGO_HOME"] = "/opt/cargo"
+	results, err := evaluator.EvaluateAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/home/gaetan/.cargo/registry", "/opt/cargo/registry"}, results)
+}
+
+func TestEvaluateAllOfDeduplicatesRealPaths(t *testing.T) {
+	evaluator := GlobEvaluator("<{env.HOME}/.cargo,{env.CARGO_HOME}>/registry",
+		"/home/gaetan/.cargo/registry",
+		"/opt/cargo/registry",
+	)
+	evaluator.context.environment["CARGO_HOME"] = "/opt/cargo"
+	evaluator.filesystem.(*MockFileSystem).links = map[string]string{
+		"/opt/cargo":          "/home/gaetan/.cargo",
+		"/opt/cargo/registry": "/home/gaetan/.cargo/registry",
+	}
+	results, err := evaluator.EvaluateAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/home/gaetan/.cargo/registry"}, results)
+}
+
+func TestEvaluateAllOfNoneExists(t *testing.T) {
+	evaluator := GlobEvaluator("<{env.HOME}/.cargo,/opt/cargo>", "/home/gaetan")
+	_, err := evaluator.EvaluateAll()
+	assert.Error(t, err)
+}
+
+func TestEvaluateAllOfWithGoRoutines(t *testing.T) {
+	evaluator := GlobEvaluator("<{env.HOME}/a,{env.HOME}/b,{env.HOME}/c>",
+		"/home/gaetan/a",
+		"/home/gaetan/b",
+		"/home/gaetan/c",
+	)
+	evaluator.lifecycle = &GoRoutinesRuntime{}
+	results, err := evaluator.EvaluateAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/home/gaetan/a", "/home/gaetan/b", "/home/gaetan/c"}, results)
+}
+
+func TestEvaluatePlaceholder(t *testing.T) {
+	evaluator := GlobEvaluator("{os==darwin:/opt/homebrew/bin/brew,linux:/home/linuxbrew/.linuxbrew/bin/brew}",
+		"/opt/homebrew/bin/brew",
+		"/home/linuxbrew/.linuxbrew/bin/brew",
+	)
+	result, err := evaluator.Evaluate()
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/linuxbrew/.linuxbrew/bin/brew", result)
+}
+
+func TestEvaluatePlaceholderInsidePath(t *testing.T) {
+	evaluator := GlobEvaluator("{env.HOME}/{os==darwin:Library/Caches,.cache}/{arch}",
+		"/home/gaetan/.cache/amd64",
+	)
+	result, err := evaluator.Evaluate()
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/gaetan/.cache/amd64", result)
+}
+
+func TestEvaluateRequiredPlaceholder(t *testing.T) {
+	evaluator := GlobEvaluator("{os==darwin:/opt/homebrew,windows:/c,!}", "/opt/homebrew")
+	_, err := evaluator.Evaluate()
+	assert.Error(t, err)
+}
+
+func TestEvaluateSyntaxError(t *testing.T) {
+	evaluator := GlobEvaluator("{env.HOME/devcleaner-go", "/home/gaetan/devcleaner-go")
+	_, err := evaluator.Evaluate()
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+}
+
+func TestCompare(t *testing.T) {
+	for _, c := range []struct {
+		left, op, right string
+		expected        bool
+	}{
+		{"linux", "==", "linux", true},
+		{"linux", "!=", "darwin", true},
+		{"9", "<", "10", true},
+		{"b", ">", "a", true},
+		{"1.5", "<", "1.50", true},
+		{"1.10", ">", "1.9", true},
+		{"1.22.19", "<", "2", true},
+		{"v2.0.0-rc.1", "<", "2.0.0", true},
+		{"2.0.0-rc.2", ">", "2.0.0-rc.1", true},
+		{"2.0.0-alpha", ">", "2.0.0-1", true},
+		{"8.5", "==", "8.5.0", true},
+		{"2.0.0+build", "==", "2.0.0", true},
+		{"1.5e1", ">", "9", true},
+		{"3.5", "<", "3.10", true},
+		{"-2", "<", "1", true},
+	} {
+		holds, err := compare(c.left, c.op, c.right)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, holds, "%s %s %s", c.left, c.op, c.right)
+	}
+}
+
+func TestEvaluateHomeShorthands(t *testing.T) {
+	for pattern, expected := range map[string]string{
+		"~/.cargo":        "/home/gaetan/.cargo",
+		"{home}/.cargo":   "/home/gaetan/.cargo",
+		"{xdg.cache}/pip": "/home/gaetan/.cache/pip",
+		"{xdg.data}/pnpm": "/home/gaetan/.local/share/pnpm",
+		"{xdg.config}/gh": "/home/gaetan/.config/gh",
+		"{tmp}/go-build*": "/tmp/go-build123",
+	} {
+		evaluator := GlobEvaluator(pattern,
+			"/home/gaetan/.cargo",
+			"/home/gaetan/.cache/pip",
+			"/home/gaetan/.local/share/pnpm",
+			"/home/gaetan/.config/gh",
+			"/tmp/go-build123",
+		)
+		result, err := evaluator.Evaluate()
+		assert.NoError(t, err, pattern)
+		assert.Equal(t, expected, result, pattern)
+	}
+}
+
+func TestEvaluateHomeWithoutEnvironment(t *testing.T) {
+	evaluator := GlobEvaluator("{xdg.cache}/pip", "/var/lib/svc/cache/pip")
+	evaluator.context = &PathContext{os: "linux", arch: "amd64", dirs: userDirs{
+		home:     "/var/lib/svc",
+		xdgCache: "/var/lib/svc/cache",
+	}}
+	result, err := evaluator.Evaluate()
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/lib/svc/cache/pip", result)
+
+	evaluator = GlobEvaluator("{xdg.cache}/pip")
+	evaluator.context = &PathContext{os: "linux", arch: "amd64"}
+	_, err = evaluator.Evaluate()
+	assert.Error(t, err)
+}
+
+func TestCurrentUserDirsWithoutHome(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("the home directory does not come from HOME")
+	}
+	u, err := user.Current()
+	if err != nil || u.HomeDir == "" {
+		t.Skip("the home directory of the account is unknown")
+	}
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "/etc/svc")
+
+	dirs := currentUserDirs()
+	assert.Equal(t, u.HomeDir, dirs.home)
+	assert.Equal(t, defaultUserDirs(u.HomeDir, runtime.GOOS).xdgCache, dirs.xdgCache)
+	assert.Equal(t, "/etc/svc", dirs.xdgConfig)
+
+	context := &PathContext{os: runtime.GOOS, dirs: dirs}
+	assert.Equal(t, u.HomeDir, context.Dir("home"))
+	assert.NotEqual(t, "/", context.Dir("home"))
+}
+
+func TestDefaultUserDirs(t *testing.T) {
+	dirs := defaultUserDirs("/Users/gaetan", "darwin")
+	assert.Equal(t, filepath.Join("/Users/gaetan", "Library", "Caches"), dirs.xdgCache)
+	dirs = defaultUserDirs("/home/gaetan", "linux")
+	assert.Equal(t, filepath.Join("/home/gaetan", ".config"), dirs.xdgConfig)
+}
+
+func TestEvaluateVariableDefault(t *testing.T) {
+	files := []string{"/home/gaetan/.cargo/registry", "/opt/cargo/registry"}
+
+	evaluator := GlobEvaluator("{env.CARGO_HOME:-{home}/.cargo}/registry", files...)
+	result, err := evaluator.Evaluate()
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/gaetan/.cargo/registry", result)
+
+	evaluator = GlobEvaluator("{env.CARGO_HOME:-{home}/.cargo}/registry", files...)
+	evaluator.context.environment["CARGO_HOME"] = "/opt/cargo"
+	result, err = evaluator.Evaluate()
+	assert.NoError(t, err)
+	assert.Equal(t, "/opt/cargo/registry", result)
+	// only the whole path is checked, not the value of the variable
+	assert.NotContains(t, evaluator.filesystem.(*MockFileSystem).requested, "/opt/cargo")
+
+	evaluator = GlobEvaluator("{env.CARGO_HOME:-}/opt/cargo/registry", files...)
+	result, err = evaluator.Evaluate()
+	assert.NoError(t, err)
+	assert.Equal(t, "/opt/cargo/registry", result)
+}
+
+func CommandEvaluator(pattern string, outputs map[string]string, files ...string) (*PathPatternEvaluator, *int) {
+	evaluator := GlobEvaluator(pattern, files...)
+	runs := 0
+	evaluator.context.runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		runs++
+		command := strings.Join(append([]string{name}, args...), " ")
+		output, ok := outputs[command]
+		if !ok {
+			return nil, errors.New("command not found")
+		}
+		return []byte(output), nil
+	}
+	return evaluator, &runs
+}
+
+func TestEvaluateCommand(t *testing.T) {
+	evaluator, runs := CommandEvaluator("{cmd:go env GOCACHE GOMODCACHE}",
+		map[string]string{"go env GOCACHE GOMODCACHE": "/home/gaetan/.cache/go-build\n/home/gaetan/go/pkg/mod\n"},
+		"/home/gaetan/.cache/go-build",
+		"/home/gaetan/go/pkg/mod",
+	)
+	results, err := evaluator.EvaluateAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/home/gaetan/.cache/go-build", "/home/gaetan/go/pkg/mod"}, results)
+	assert.Equal(t, 1, *runs)
+
+	_, err = evaluator.EvaluateAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, *runs, "command outputs are cached in the context")
+}
+
+func TestEvaluateCommandErrors(t *testing.T) {
+	evaluator, _ := CommandEvaluator("{cmd:pip cache dir}", nil)
+	_, err := evaluator.Evaluate()
+	assert.ErrorContains(t, err, "command not found")
+
+	evaluator, _ = CommandEvaluator("{cmd:pip cache dir}", map[string]string{"pip cache dir": "\n"})
+	_, err = evaluator.Evaluate()
+	assert.ErrorContains(t, err, "no output")
+
+	evaluator, runs := CommandEvaluator("{cmd:pip cache dir}", map[string]string{"pip cache dir": "/a"}, "/a")
+	evaluator.context.SetSafeMode(true)
+	_, err = evaluator.Evaluate()
+	assert.ErrorContains(t, err, ErrSafeMode.Error())
+	assert.Equal(t, 0, *runs)
+}
+
+func TestEvaluateRestrictedCommands(t *testing.T) {
+	outputs := map[string]string{
+		"go env GOCACHE":                     "/a",
+		"/tmp/go env GOCACHE":                "/a",
+		"sh -c touch /tmp/x":                 "/a",
+		"go env GOCACHE GOPATH":              "/a",
+		"go env -w GOFLAGS=-toolexec=/tmp/x": "/a",
+		"go env -u GOPROXY":                  "/a",
+		"go version --":                      "/a",
+	}
+	for pattern, allowed := range map[string]bool{
+		"{cmd:go env GOCACHE}":                     true,
+		"{cmd:go env GOCACHE GOPATH}":              true,
+		"{cmd:go env -w GOFLAGS=-toolexec=/tmp/x}": false,
+		"{cmd:go env -u GOPROXY}":                  false,
+		"{cmd:go version --}":                      false,
+		"{cmd:go run ./evil}":                      false,
+		"{cmd:/tmp/go env GOCACHE}":                false,
+		"{cmd:sh -c touch /tmp/x}":                 false,
+	} {
+		evaluator, runs := CommandEvaluator(pattern, outputs, "/a")
+		evaluator.context = evaluator.context.RestrictCommands([]string{"go env", "go version"})
+		_, err := evaluator.Evaluate()
+		if allowed {
+			assert.NoError(t, err, pattern)
+			assert.Equal(t, 1, *runs, pattern)
+		} else {
+			assert.ErrorContains(t, err, "not allowed", pattern)
+			assert.Equal(t, 0, *runs, pattern)
+		}
+	}
+
+	evaluator, runs := CommandEvaluator("{cmd:go env GOCACHE}", outputs, "/a")
+	evaluator.context = evaluator.context.RestrictCommands(nil)
+	_, err := evaluator.Evaluate()
+	assert.ErrorContains(t, err, "not allowed")
+	assert.Equal(t, 0, *runs)
+}
+
+func TestExecCommandDoesNotWaitForItsChildren(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+	start := time.Now()
+	// the background sleep inherits the output of the shell
+	out, err := execCommand(context.Background(), "sh", "-c", "echo ok; sleep 10 &")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", string(out))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestEvaluateCommandTimeout(t *testing.T) {
+	evaluator := GlobEvaluator("{cmd:sleep 10}")
+	evaluator.context.SetCommandTimeout(10 * time.Millisecond)
+	evaluator.context.runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	_, err := evaluator.Evaluate()
+	assert.ErrorContains(t, err, "timed out after 10ms")
+}
+
+func TestEvaluateVersionCondition(t *testing.T) {
+	pattern := "{xdg.cache}/{version>=2:yarn/berry,yarn/v6}"
+	files := []string{"/home/gaetan/.cache/yarn/berry", "/home/gaetan/.cache/yarn/v6"}
+	for version, expected := range map[string]string{
+		"1.22.19":     "/home/gaetan/.cache/yarn/v6",
+		"4.0.2":       "/home/gaetan/.cache/yarn/berry",
+		"2.0.0-rc.36": "/home/gaetan/.cache/yarn/v6",
+	} {
+		evaluator := GlobEvaluator(pattern, files...)
+		evaluator.context = evaluator.context.ForApp("/usr/bin/yarn", version)
+		result, err := evaluator.Evaluate()
+		assert.NoError(t, err, version)
+		assert.Equal(t, expected, result, version)
+	}
+
+	evaluator := GlobEvaluator(pattern, files...)
+	_, err := evaluator.Evaluate()
+	assert.ErrorContains(t, err, "version not set")
+}
+
+func flattenTrace(t *Trace, depth int) []string {
+	lines := []string{strings.Repeat("  ", depth) + t.Message}
+	for _, child := range t.Children {
+		lines = append(lines, flattenTrace(child, depth+1)...)
+	}
+	return lines
+}
+
+func TestTrace(t *testing.T) {
+	evaluator := GlobEvaluator("[{env.CARGO_HOME},{home}/.cargo]/{os==darwin:mac,linux}", "/home/gaetan/.cargo/linux")
+	evaluator.trace = &Trace{Message: evaluator.pattern}
+	_, err := evaluator.Evaluate()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"[{env.CARGO_HOME},{home}/.cargo]/{os==darwin:mac,linux}",
+		"  either [{env.CARGO_HOME},{home}/.cargo] = [/home/gaetan/.cargo]",
+		"    option {env.CARGO_HOME}: error evaluating variable env.CARGO_HOME (environment variable CARGO_HOME not set)",
+		"      variable env.CARGO_HOME: environment variable CARGO_HOME not set",
+		"    option {home}/.cargo = [/home/gaetan/.cargo]",
+		"      variable home = [/home/gaetan]",
+		"        stat /home/gaetan: exists",
+		"      stat /home/gaetan/.cargo: exists",
+		"  placeholder {os==darwin:mac,linux} = [linux]",
+		"    condition os==darwin does not hold (linux == darwin)",
+		"    using the default linux",
+		"  stat /home/gaetan/.cargo/linux: exists",
+	}, flattenTrace(evaluator.trace, 0))
+	assert.Equal(t, TraceRejected, evaluator.trace.Children[0].Children[0].Status)
+	assert.Equal(t, TraceAccepted, evaluator.trace.Children[0].Children[1].Status)
+}
+
+func TestEvaluateWithRoot(t *testing.T) {
+	filesystem := &MockFileSystem{filesystem: MockFileSystemMap(
+		"/mnt/backup/home/gaetan/.cargo/registry",
+		"/mnt/backup/home/gaetan/.gradle/caches/8.5",
+		"/mnt/backup/opt/tool",
+	)}
+	context := &PathContext{os: "linux", arch: "amd64", environment: map[string]string{"HOME": "/home/gaetan"}}
+	for pattern, expected := range map[string][]string{
+		"{home}/.cargo/registry":    {"/mnt/backup/home/gaetan/.cargo/registry"},
+		"{home}/.gradle/caches/*":   {"/mnt/backup/home/gaetan/.gradle/caches/8.5"},
+		"[/usr/tool,/opt/tool]":     {"/mnt/backup/opt/tool"},
+		"<{home}/.cargo,/opt/tool>": {"/mnt/backup/home/gaetan/.cargo", "/mnt/backup/opt/tool"},
+	} {
+		results, err := PathPattern(pattern).EvalAll(context,
+			WithFileSystem(filesystem),
+			WithRoot("/mnt/backup"),
+			WithRuntime(&GoRoutinesRuntime{}),
+			WithLogger(Logger()),
+		)
+		assert.NoError(t, err, pattern)
+		assert.Equal(t, expected, results, pattern)
+	}
+}
+
+func TestEvaluateWithRootResolvesLinksInRoot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges")
+	}
+	root := t.TempDir()
+	host := t.TempDir()
+	for _, dir := range []string{"home/gaetan", "opt/cache/v1", "etc"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(host, "secret"), []byte("host"), 0644))
+	links := map[string]string{
+		// absolute links point into the image, not to this system
+		"home/gaetan/.cache": "/opt/cache",
+		"home/gaetan/.host":  host,
+		"home/gaetan/.up":    "../../../..",
+	}
+	for link, target := range links {
+		assert.NoError(t, os.Symlink(target, filepath.Join(root, link)))
+	}
+	context := &PathContext{os: "linux", arch: "amd64", environment: map[string]string{"HOME": "/home/gaetan"}}
+	eval := func(pattern string) ([]string, error) {
+		return PathPattern(pattern).EvalAll(context, WithRoot(root), WithLogger(Logger()))
+	}
+
+	results, err := eval("{home}/.cache/*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "opt/cache/v1")}, results)
+
+	results, err = eval("{home}/.cache")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "opt/cache")}, results)
+
+	for _, pattern := range []string{
+		"{home}/.host/secret",
+		"{home}/../../../../" + strings.TrimLeft(filepath.ToSlash(host), "/") + "/secret",
+		"{home}/.up/" + strings.TrimLeft(filepath.ToSlash(host), "/") + "/secret",
+	} {
+		results, err := eval(pattern)
+		assert.Error(t, err, pattern)
+		assert.Empty(t, results, pattern)
+	}
+
+	_, err = eval("{home}/../../../etc")
+	assert.ErrorContains(t, err, "leaves the root")
+	results, err = eval("{home}/../../etc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "etc")}, results)
+}
+
+func TestSyntheticPathContext(t *testing.T) {
+	context := NewSyntheticPathContext("/home/agent", "linux", "arm64", map[string]string{
+		"XDG_CACHE_HOME": "/var/cache/agent",
+		"GOCACHE":        "/opt/gocache",
+	})
+	filesystem := &MockFileSystem{filesystem: MockFileSystemMap(
+		"/images/agent/var/cache/agent/pip",
+		"/images/agent/home/agent/.config/gh",
+		"/images/agent/home/agent/.cargo",
+		"/images/agent/opt/gocache",
+		"/images/agent/arm64",
+	)}
+	for pattern, expected := range map[string]string{
+		"{xdg.cache}/pip":   "/images/agent/var/cache/agent/pip",
+		"{xdg.config}/gh":   "/images/agent/home/agent/.config/gh",
+		"{env.HOME}/.cargo": "/images/agent/home/agent/.cargo",
+		"{env.GOCACHE}":     "/images/agent/opt/gocache",
+		"/{arch}":           "/images/agent/arm64",
+	} {
+		result, err := PathPattern(pattern).Eval(context, WithFileSystem(filesystem), WithRoot("/images/agent"), WithLogger(Logger()))
+		assert.NoError(t, err, pattern)
+		assert.Equal(t, expected, result, pattern)
+	}
+
+	_, err := PathPattern("{cmd:go env GOCACHE}").Eval(context, WithFileSystem(filesystem), WithRoot("/images/agent"))
+	assert.ErrorContains(t, err, ErrSafeMode.Error())
+}
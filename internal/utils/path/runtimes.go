@@ -5,16 +5,18 @@ import (
 	"sync"
 )
 
+// Runs the evaluation of the options of either and all-of, each option
+// evaluates to one or more paths.
 type Runtime interface {
-	LifecycleManager[string, ExistingPath]
+	LifecycleManager[Sequence, []ExistingPath]
 }
 
 type DefaultRuntime struct {
-	SynchronousLifecycleManager[string, ExistingPath]
+	SynchronousLifecycleManager[Sequence, []ExistingPath]
 }
 
 type GoRoutinesRuntime struct {
-	GoRoutinesLifecycleManager[string, ExistingPath]
+	GoRoutinesLifecycleManager[Sequence, []ExistingPath]
 	quit chan int
 }
 
@@ -47,6 +49,23 @@ func FirstResult[T any](t []TaskResult[T]) (T, error) {
 	return last.Result, last.Err
 }
 
+// Returns the successful results, in order. Fails only if no task succeeded.
+func AllResults[T any](t []TaskResult[T]) ([]T, error) {
+	var results []T
+	var lastErr error
+	for _, r := range t {
+		if r.Err == nil {
+			results = append(results, r.Result)
+		} else {
+			lastErr = r.Err
+		}
+	}
+	if len(results) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return results, nil
+}
+
 func WithoutError[T any](t []TaskResult[T]) ([]T, error) {
 	results := make([]T, len(t))
 	for i, r := range t {
@@ -89,33 +108,21 @@ func (m *GoRoutinesLifecycleManager[T, R]) AllowedToRun() bool {
 
 func (m *GoRoutinesLifecycleManager[T, R]) RunAll(f func(int, T) (R, error), elems []T) []TaskResult[R] {
 	// fmt.Println("Running in goroutines")
-	ch := make(chan R, len(elems))
-	defer close(ch)
-	err_ch := make(chan error, len(elems))
-	defer close(err_ch)
+	// each goroutine writes to its own index so that results are in the
+	// same order as elems, as with the synchronous manager
+	results := make([]TaskResult[R], len(elems))
 	var wg sync.WaitGroup
 	wg.Add(len(elems))
 	for i, elem := range elems {
 		go func(i int, elem T) {
 			defer wg.Done()
 			if result, err := f(i, elem); err == nil {
-				ch <- result
-				err_ch <- nil
+				results[i] = TaskResult[R]{Result: result}
 			} else {
-				ch <- result
-				err_ch <- err
+				results[i] = TaskResult[R]{Err: err}
 			}
 		}(i, elem)
 	}
 	wg.Wait()
-
-	results := make([]TaskResult[R], len(elems))
-	for i := 0; i < len(elems); i++ {
-		if res, err := <-ch, <-err_ch; err == nil {
-			results[i] = TaskResult[R]{Result: res}
-		} else {
-			results[i] = TaskResult[R]{Err: err}
-		}
-	}
 	return results
 }
@@ -77,3 +77,16 @@ func TestGoRoutinesIsFaster(t *testing.T) {
 	fmt.Printf("GoRoutines is %.2fx %s than synchronous\n", by, faster)
 	assert.Lessf(t, took1, took2, "GoRoutines is faster than synchronous")
 }
+
+func TestAllResults(t *testing.T) {
+	results, err := AllResults([]TaskResult[string]{
+		{Result: "a"},
+		{Err: fmt.Errorf("b")},
+		{Result: "c"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, results)
+
+	_, err = AllResults([]TaskResult[string]{{Err: fmt.Errorf("a")}})
+	assert.EqualError(t, err, "a")
+}
@@ -0,0 +1,92 @@
+package path
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+type TraceStatus int
+
+const (
+	TraceInfo TraceStatus = iota
+	TraceAccepted
+	TraceRejected
+)
+
+// A step of the evaluation of a pattern, see PathPattern.Explain.
+type Trace struct {
+	Message  string
+	Status   TraceStatus
+	Children []*Trace
+}
+
+// Adds a child step. Does nothing on a nil trace, so that evaluators that
+// are not tracing do not have to check.
+func (t *Trace) add(status TraceStatus, format string, args ...any) *Trace {
+	if t == nil {
+		return nil
+	}
+	child := &Trace{Message: fmt.Sprintf(format, args...), Status: status}
+	t.Children = append(t.Children, child)
+	return child
+}
+
+// Marks the step as rejected with err, or as accepted with the given detail.
+func (t *Trace) done(err error, format string, args ...any) {
+	if t == nil {
+		return
+	}
+	if err != nil {
+		t.Status = TraceRejected
+		t.Message += ": " + err.Error()
+		return
+	}
+	t.Status = TraceAccepted
+	if format != "" {
+		t.Message += " " + fmt.Sprintf(format, args...)
+	}
+}
+
+// Evaluates the pattern like EvalAll, also returning every step taken: the
+// variables substituted, the options tried and the paths stat'ed.
+// Options of either and all-of are evaluated sequentially.
+func (p PathPattern) Explain(context *PathContext, opts ...Option) (*Trace, []string, error) {
+	evaluator := p.evaluator(context, opts)
+	evaluator.lifecycle = &DefaultRuntime{}
+	evaluator.trace = &Trace{Message: string(p)}
+	paths, err := evaluator.EvaluateAll()
+	evaluator.trace.done(err, "")
+	return evaluator.trace, paths, err
+}
+
+func (p *PathPatternEvaluator) tracef(status TraceStatus, format string, args ...any) *Trace {
+	return p.trace.add(status, format, args...)
+}
+
+// Returns an evaluator recording its steps under t.
+func (p *PathPatternEvaluator) tracing(t *Trace) *PathPatternEvaluator {
+	if t == nil {
+		return p
+	}
+	traced := *p
+	traced.trace = t
+	return &traced
+}
+
+// Stats a path, recording why it was accepted or rejected.
+func (p *PathPatternEvaluator) stat(path string) error {
+	_, err := p.filesystem.Stat(path)
+	if err != nil {
+		reason := err
+		// the path is already in the message
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			reason = pathErr.Err
+		}
+		p.tracef(TraceRejected, "stat %s: %s", path, reason)
+	} else {
+		p.tracef(TraceAccepted, "stat %s: exists", path)
+	}
+	return err
+}
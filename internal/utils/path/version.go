@@ -0,0 +1,95 @@
+package path
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A version like `1.22.19`, `v2.0.0-rc.1` or `8.5`, compared like semver
+// with missing components being 0.
+type version struct {
+	components []int
+	prerelease []string
+}
+
+func parseVersion(s string) (version, bool) {
+	s = strings.TrimPrefix(s, "v")
+	// build metadata is ignored by comparisons
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	var v version
+	for _, part := range strings.Split(s, ".") {
+		if part == "" || strings.TrimLeft(part, "0123456789") != "" {
+			return version{}, false
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version{}, false
+		}
+		v.components = append(v.components, n)
+	}
+	if hasPre {
+		if pre == "" {
+			return version{}, false
+		}
+		v.prerelease = strings.Split(pre, ".")
+	}
+	return v, true
+}
+
+func compareVersions(a version, b version) int {
+	for i := 0; i < max(len(a.components), len(b.components)); i++ {
+		var x, y int
+		if i < len(a.components) {
+			x = a.components[i]
+		}
+		if i < len(b.components) {
+			y = b.components[i]
+		}
+		if x != y {
+			return cmpInt(x, y)
+		}
+	}
+	// a prerelease comes before the release
+	switch {
+	case a.prerelease == nil && b.prerelease == nil:
+		return 0
+	case a.prerelease == nil:
+		return 1
+	case b.prerelease == nil:
+		return -1
+	}
+	for i := 0; i < min(len(a.prerelease), len(b.prerelease)); i++ {
+		if cmp := comparePrerelease(a.prerelease[i], b.prerelease[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	return cmpInt(len(a.prerelease), len(b.prerelease))
+}
+
+// Numeric identifiers are compared numerically and come before the others.
+func comparePrerelease(a string, b string) int {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return cmpInt(x, y)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func cmpInt(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
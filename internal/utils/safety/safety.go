@@ -0,0 +1,159 @@
+package safety
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Decides whether a path may be deleted, so that a bad manifest entry
+// cannot delete a root, a home or a project.
+type Guard struct {
+	Home string
+	// Working directory, it and its ancestors are never deleted
+	Cwd string
+	// Only paths strictly inside these directories are deleted
+	Allowed []string
+	// Paths that are never deleted, nor any directory containing them
+	Protected []string
+}
+
+// Directories of the home directory where tools keep their caches, which
+// may be deleted by default besides the cache and temporary directories.
+var ToolCacheDirs = []string{
+	".cargo/registry", ".cargo/git",
+	".gradle/caches", ".gradle/wrapper/dists",
+	".m2/repository",
+	".npm", ".yarn/berry/cache", ".local/share/pnpm/store",
+	"go/pkg/mod",
+	".pub-cache",
+	".nuget/packages",
+	".bun/install/cache",
+	".android/cache",
+	"Library/Developer/Xcode/DerivedData",
+}
+
+// Returns a guard only allowing deletions in the cache and temporary
+// directories of a user, the ToolCacheDirs of their home, and the given
+// directories. Any other directory of the home, e.g. ~/Documents or
+// ~/.ssh, must be allowed explicitly.
+func NewGuard(home string, cacheDir string, tmpDir string, allowed []string) *Guard {
+	guard := &Guard{Home: home}
+	for _, dir := range append([]string{cacheDir, tmpDir}, allowed...) {
+		if dir != "" {
+			guard.Allowed = append(guard.Allowed, dir)
+		}
+	}
+	if home != "" {
+		for _, dir := range ToolCacheDirs {
+			guard.Allowed = append(guard.Allowed, filepath.Join(home, dir))
+		}
+	}
+	return guard
+}
+
+// A deletion refused by a Guard.
+type RefusedError struct {
+	Path   string
+	Reason string
+}
+
+func (e *RefusedError) Error() string {
+	return fmt.Sprintf("refusing to delete %s, %s", e.Path, e.Reason)
+}
+
+// Returns a *RefusedError if p must not be deleted. Symbolic links in the
+// parents of p are resolved, both the given and the resolved path are
+// checked.
+func (g *Guard) Check(p string) error {
+	if !filepath.IsAbs(p) {
+		return &RefusedError{Path: p, Reason: "it is not absolute"}
+	}
+	p = filepath.Clean(p)
+	if err := g.check(p, p); err != nil {
+		return err
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(p)); err == nil {
+		if resolved := filepath.Join(dir, filepath.Base(p)); resolved != p {
+			return g.check(p, resolved)
+		}
+	}
+	return nil
+}
+
+func (g *Guard) check(p string, resolved string) error {
+	refuse := func(format string, args ...any) error {
+		reason := fmt.Sprintf(format, args...)
+		if resolved != p {
+			reason = fmt.Sprintf("it resolves to %s and %s", resolved, reason)
+		}
+		return &RefusedError{Path: p, Reason: reason}
+	}
+	if filepath.Dir(resolved) == resolved {
+		return refuse("it is a filesystem root")
+	}
+	for _, home := range variants(g.Home) {
+		if resolved == home {
+			return refuse("it is the home directory")
+		}
+	}
+	for _, cwd := range variants(g.Cwd) {
+		if within(cwd, resolved) {
+			return refuse("it contains the working directory")
+		}
+	}
+	for _, protected := range g.Protected {
+		for _, v := range variants(protected) {
+			if within(resolved, v) || within(v, resolved) {
+				return refuse("it is protected by %s", protected)
+			}
+		}
+	}
+	for _, allowed := range g.Allowed {
+		for _, v := range variants(allowed) {
+			if within(resolved, v) && resolved != v {
+				return nil
+			}
+		}
+	}
+	return refuse("it is outside the allowed directories")
+}
+
+// Returns the file p refers to, to check later that it did not change.
+func Snapshot(p string) (os.FileInfo, error) {
+	return os.Lstat(p)
+}
+
+// Checks p and deletes it, provided it is still the file seen by Snapshot.
+func (g *Guard) RemoveAll(p string, seen os.FileInfo) error {
+	if err := g.Check(p); err != nil {
+		return err
+	}
+	info, err := os.Lstat(p)
+	if err != nil {
+		return err
+	}
+	if seen == nil || !os.SameFile(seen, info) {
+		return &RefusedError{Path: p, Reason: "it changed since it was scanned"}
+	}
+	return os.RemoveAll(p)
+}
+
+// Reports whether p is dir or is inside it.
+func within(p string, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(p))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Returns dir and, if it differs, dir with its symbolic links resolved.
+func variants(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	dir = filepath.Clean(dir)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil && resolved != dir {
+		return []string{dir, resolved}
+	}
+	return []string{dir}
+}
@@ -0,0 +1,98 @@
+package safety
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	home := t.TempDir()
+	project := filepath.Join(home, "src", "project")
+	guard := &Guard{
+		Home:      home,
+		Cwd:       project,
+		Allowed:   []string{home, "/var/cache"},
+		Protected: []string{filepath.Join(home, ".m2", "repository")},
+	}
+	for p, reason := range map[string]string{
+		"relative/cache":                      "it is not absolute",
+		string(filepath.Separator):            "it is a filesystem root",
+		home:                                  "it is the home directory",
+		filepath.Join(home, "src"):            "it contains the working directory",
+		project:                               "it contains the working directory",
+		filepath.Join(home, ".m2"):            "it is protected by",
+		filepath.Join(home, ".m2/repository"): "it is protected by",
+		"/opt/cache":                          "it is outside the allowed directories",
+		"/var/cache":                          "it is outside the allowed directories",
+	} {
+		var refused *RefusedError
+		if assert.ErrorAs(t, guard.Check(p), &refused, p) {
+			assert.Contains(t, refused.Reason, reason, p)
+		}
+	}
+	for _, p := range []string{
+		filepath.Join(home, ".cache", "go-build"),
+		filepath.Join(project, "target"),
+		"/var/cache/apt",
+	} {
+		assert.NoError(t, guard.Check(p), p)
+	}
+}
+
+func TestNewGuardOnlyAllowsCaches(t *testing.T) {
+	home := t.TempDir()
+	tmp := t.TempDir()
+	guard := NewGuard(home, filepath.Join(home, ".cache"), tmp, []string{"/opt/ci/cache"})
+	for _, p := range []string{
+		filepath.Join(home, "Documents"),
+		filepath.Join(home, "Documents", "taxes"),
+		filepath.Join(home, ".ssh"),
+		filepath.Join(home, ".ssh", "id_ed25519"),
+		filepath.Join(home, ".config", "gh"),
+		filepath.Join(home, "src", "project"),
+		filepath.Join(home, ".cargo"),
+		filepath.Join(home, ".cargo", "bin"),
+	} {
+		assert.ErrorContains(t, guard.Check(p), "it is outside the allowed directories", p)
+	}
+	for _, p := range []string{
+		filepath.Join(home, ".cache", "pip"),
+		filepath.Join(tmp, "go-build123"),
+		filepath.Join(home, ".cargo", "registry", "cache"),
+		filepath.Join(home, ".gradle", "caches", "8.5"),
+		"/opt/ci/cache/bazel",
+	} {
+		assert.NoError(t, guard.Check(p), p)
+	}
+}
+
+func TestCheckResolvesSymlinks(t *testing.T) {
+	home := t.TempDir()
+	assert.NoError(t, os.Symlink("/", filepath.Join(home, "link")))
+	guard := &Guard{Home: home, Allowed: []string{home}}
+	assert.ErrorContains(t, guard.Check(filepath.Join(home, "link", "etc")), "it resolves to /etc")
+}
+
+func TestRemoveAllChecksIdentity(t *testing.T) {
+	home := t.TempDir()
+	cache := filepath.Join(home, "cache")
+	assert.NoError(t, os.Mkdir(cache, 0755))
+	guard := &Guard{Home: home, Allowed: []string{home}}
+
+	seen, err := Snapshot(cache)
+	assert.NoError(t, err)
+	// replaced by another directory after the scan, the old one is kept so
+	// that its inode is not reused
+	assert.NoError(t, os.Rename(cache, filepath.Join(home, "old")))
+	assert.NoError(t, os.Mkdir(cache, 0755))
+	assert.ErrorContains(t, guard.RemoveAll(cache, seen), "it changed since it was scanned")
+	assert.DirExists(t, cache)
+
+	seen, err = Snapshot(cache)
+	assert.NoError(t, err)
+	assert.NoError(t, guard.RemoveAll(cache, seen))
+	assert.NoDirExists(t, cache)
+}
@@ -0,0 +1,311 @@
+package schedule
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// How the scheduled clean is run.
+type Backend string
+
+const (
+	Systemd = Backend("systemd")
+	Cron    = Backend("cron")
+)
+
+const unitName = "devcleaner-clean"
+
+// Marks the crontab entry, to find it again.
+const cronMarker = "# devcleaner clean"
+
+// A weekly, non interactive clean of the caches.
+type Schedule struct {
+	Backend Backend
+	// Executable of devcleaner
+	Exe string
+	// File the output of the cleans is appended to
+	LogFile string
+	// Directory of the systemd user units
+	UnitDir string
+
+	// Runs a command with the given input and returns its output, for tests
+	run func(stdin string, name string, args ...string) (string, error)
+}
+
+// Returns the systemd backend if systemd is running, else cron if crontab is
+// available.
+func DetectBackend() (Backend, error) {
+	if _, err := os.Stat("/run/systemd/system"); err == nil {
+		if _, err := exec.LookPath("systemctl"); err == nil {
+			return Systemd, nil
+		}
+	}
+	if _, err := exec.LookPath("crontab"); err == nil {
+		return Cron, nil
+	}
+	return "", errors.New("neither systemd nor cron is available")
+}
+
+func ParseBackend(s string) (Backend, error) {
+	switch Backend(s) {
+	case Systemd, Cron:
+		return Backend(s), nil
+	default:
+		return "", fmt.Errorf("unknown backend %s, expected systemd or cron", s)
+	}
+}
+
+func (s *Schedule) runCommand(stdin string, name string, args ...string) (string, error) {
+	if s.run != nil {
+		return s.run(stdin, name, args...)
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return string(out), fmt.Errorf("%s %s: %s (%s)", name, strings.Join(args, " "), msg, err)
+		}
+		return string(out), fmt.Errorf("%s %s: %s", name, strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+func (s *Schedule) command() string {
+	return quote(s.Exe) + " clean -scheduled"
+}
+
+func quote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Installs the schedule, replacing a previous one.
+func (s *Schedule) Install() error {
+	if err := os.MkdirAll(filepath.Dir(s.LogFile), 0755); err != nil {
+		return err
+	}
+	switch s.Backend {
+	case Systemd:
+		return s.installSystemd()
+	case Cron:
+		return s.installCron()
+	default:
+		return fmt.Errorf("unknown backend %s", s.Backend)
+	}
+}
+
+// Removes the schedule, if installed.
+func (s *Schedule) Remove() error {
+	switch s.Backend {
+	case Systemd:
+		return s.removeSystemd()
+	case Cron:
+		return s.removeCron()
+	default:
+		return fmt.Errorf("unknown backend %s", s.Backend)
+	}
+}
+
+// Reports whether the schedule is installed, with a description of its
+// state.
+func (s *Schedule) Status() (bool, string, error) {
+	switch s.Backend {
+	case Systemd:
+		return s.statusSystemd()
+	case Cron:
+		return s.statusCron()
+	default:
+		return false, "", fmt.Errorf("unknown backend %s", s.Backend)
+	}
+}
+
+// Returns the service and timer units running the clean. The log file cannot
+// contain whitespace, which systemd does not allow after append:.
+func (s *Schedule) systemdUnits() (string, string, error) {
+	if strings.ContainsAny(s.Exe, "\n\r") {
+		return "", "", fmt.Errorf("the path of the executable %q contains a line break", s.Exe)
+	}
+	if strings.ContainsAny(s.LogFile, " \t\n\r") {
+		return "", "", fmt.Errorf("the log file %q contains whitespace, which systemd cannot append to, use -backend cron", s.LogFile)
+	}
+	service := fmt.Sprintf(`[Unit]
+Description=Clean developer caches
+
+[Service]
+Type=oneshot
+Environment=NO_COLOR=1
+ExecStart=%s clean -scheduled
+StandardOutput=append:%s
+StandardError=append:%s
+Nice=19
+IOSchedulingClass=idle
+`, systemdQuote(s.Exe), systemdEscape(s.LogFile), systemdEscape(s.LogFile))
+	timer := `[Unit]
+Description=Clean developer caches weekly
+
+[Timer]
+OnCalendar=weekly
+Persistent=true
+RandomizedDelaySec=1h
+
+[Install]
+WantedBy=timers.target
+`
+	return service, timer, nil
+}
+
+// Quotes an argument of ExecStart, where systemd also expands specifiers
+// and environment variables.
+func systemdQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$").Replace(arg) + `"`
+}
+
+// Escapes the specifiers of a unit setting, e.g. a path.
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+func (s *Schedule) unitPath(kind string) string {
+	return filepath.Join(s.UnitDir, unitName+"."+kind)
+}
+
+func (s *Schedule) installSystemd() error {
+	service, timer, err := s.systemdUnits()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.UnitDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.unitPath("service"), []byte(service), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.unitPath("timer"), []byte(timer), 0644); err != nil {
+		return err
+	}
+	if _, err := s.runCommand("", "systemctl", "--user", "daemon-reload"); err != nil {
+		return err
+	}
+	_, err = s.runCommand("", "systemctl", "--user", "enable", "--now", unitName+".timer")
+	return err
+}
+
+func (s *Schedule) removeSystemd() error {
+	if _, err := os.Stat(s.unitPath("timer")); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if _, err := s.runCommand("", "systemctl", "--user", "disable", "--now", unitName+".timer"); err != nil {
+		return err
+	}
+	for _, kind := range []string{"timer", "service"} {
+		if err := os.Remove(s.unitPath(kind)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	_, err := s.runCommand("", "systemctl", "--user", "daemon-reload")
+	return err
+}
+
+func (s *Schedule) statusSystemd() (bool, string, error) {
+	if _, err := os.Stat(s.unitPath("timer")); errors.Is(err, os.ErrNotExist) {
+		return false, "", nil
+	}
+	out, err := s.runCommand("", "systemctl", "--user", "list-timers", "--all", "--no-pager", unitName+".timer")
+	return true, strings.TrimSpace(out), err
+}
+
+// Returns the crontab entry running the clean. Entries are single lines,
+// the paths cannot contain line breaks.
+func (s *Schedule) cronEntry() (string, error) {
+	if strings.ContainsAny(s.Exe+s.LogFile, "\n\r") {
+		return "", fmt.Errorf("the path of the executable %q or the log file %q contains a line break", s.Exe, s.LogFile)
+	}
+	return fmt.Sprintf("@weekly NO_COLOR=1 nice -n 19 %s >> %s 2>&1 %s", cronEscape(s.command()), cronEscape(quote(s.LogFile)), cronMarker), nil
+}
+
+// Escapes the % of a crontab command, which cron turns into line breaks.
+func cronEscape(command string) string {
+	return strings.ReplaceAll(command, "%", `\%`)
+}
+
+// Returns the lines of the crontab except the entry of the clean, and
+// whether there was one.
+func withoutCronEntry(crontab string) ([]string, bool) {
+	var lines []string
+	found := false
+	if crontab == "" {
+		return nil, false
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(crontab, "\n"), "\n") {
+		if strings.HasSuffix(line, cronMarker) {
+			found = true
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	return lines, found
+}
+
+// Returns the current crontab, which is empty if the user has none.
+func (s *Schedule) crontab() (string, error) {
+	out, err := s.runCommand("", "crontab", "-l")
+	if err != nil && strings.Contains(err.Error(), "no crontab") {
+		return "", nil
+	}
+	return out, err
+}
+
+func (s *Schedule) writeCrontab(lines []string) error {
+	content := strings.Join(lines, "\n")
+	if strings.TrimSpace(content) == "" {
+		_, err := s.runCommand("", "crontab", "-r")
+		return err
+	}
+	_, err := s.runCommand(content+"\n", "crontab", "-")
+	return err
+}
+
+func (s *Schedule) installCron() error {
+	crontab, err := s.crontab()
+	if err != nil {
+		return err
+	}
+	entry, err := s.cronEntry()
+	if err != nil {
+		return err
+	}
+	lines, _ := withoutCronEntry(crontab)
+	return s.writeCrontab(append(lines, entry))
+}
+
+func (s *Schedule) removeCron() error {
+	crontab, err := s.crontab()
+	if err != nil {
+		return err
+	}
+	lines, found := withoutCronEntry(crontab)
+	if !found {
+		return nil
+	}
+	return s.writeCrontab(lines)
+}
+
+func (s *Schedule) statusCron() (bool, string, error) {
+	crontab, err := s.crontab()
+	if err != nil {
+		return false, "", err
+	}
+	for _, line := range strings.Split(crontab, "\n") {
+		if strings.HasSuffix(line, cronMarker) {
+			return true, line, nil
+		}
+	}
+	return false, "", nil
+}
@@ -0,0 +1,137 @@
+package schedule
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Records the commands run and emulates crontab.
+type fakeSystem struct {
+	commands []string
+	crontab  string
+}
+
+func (f *fakeSystem) run(stdin string, name string, args ...string) (string, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	f.commands = append(f.commands, command)
+	switch command {
+	case "crontab -l":
+		if f.crontab == "" {
+			return "", errors.New("crontab -l: no crontab for gaetan (exit status 1)")
+		}
+		return f.crontab, nil
+	case "crontab -":
+		f.crontab = stdin
+	case "crontab -r":
+		f.crontab = ""
+	}
+	return "", nil
+}
+
+func newSchedule(t *testing.T, backend Backend) (*Schedule, *fakeSystem) {
+	dir := t.TempDir()
+	fake := &fakeSystem{}
+	return &Schedule{
+		Backend: backend,
+		Exe:     "/usr/local/bin/sao",
+		LogFile: filepath.Join(dir, "state", "clean.log"),
+		UnitDir: filepath.Join(dir, "systemd", "user"),
+		run:     fake.run,
+	}, fake
+}
+
+func TestSystemd(t *testing.T) {
+	s, fake := newSchedule(t, Systemd)
+	installed, _, err := s.Status()
+	assert.NoError(t, err)
+	assert.False(t, installed)
+
+	assert.NoError(t, s.Install())
+	service, err := os.ReadFile(filepath.Join(s.UnitDir, "devcleaner-clean.service"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(service), `ExecStart="/usr/local/bin/sao" clean -scheduled`)
+	assert.Contains(t, string(service), "StandardOutput=append:"+s.LogFile)
+	assert.FileExists(t, filepath.Join(s.UnitDir, "devcleaner-clean.timer"))
+	assert.DirExists(t, filepath.Dir(s.LogFile))
+	assert.Equal(t, []string{"systemctl --user daemon-reload", "systemctl --user enable --now devcleaner-clean.timer"}, fake.commands)
+
+	installed, _, err = s.Status()
+	assert.NoError(t, err)
+	assert.True(t, installed)
+
+	fake.commands = nil
+	assert.NoError(t, s.Remove())
+	assert.NoFileExists(t, filepath.Join(s.UnitDir, "devcleaner-clean.timer"))
+	assert.Equal(t, []string{"systemctl --user disable --now devcleaner-clean.timer", "systemctl --user daemon-reload"}, fake.commands)
+}
+
+func TestCron(t *testing.T) {
+	s, fake := newSchedule(t, Cron)
+	fake.crontab = "MAILTO=me@example.com\n\n0 * * * * backup\n"
+
+	assert.NoError(t, s.Install())
+	// installing again replaces the entry
+	assert.NoError(t, s.Install())
+	entry := "@weekly NO_COLOR=1 nice -n 19 '/usr/local/bin/sao' clean -scheduled >> '" + s.LogFile + "' 2>&1 # devcleaner clean"
+	assert.Equal(t, "MAILTO=me@example.com\n\n0 * * * * backup\n"+entry+"\n", fake.crontab)
+
+	installed, line, err := s.Status()
+	assert.NoError(t, err)
+	assert.True(t, installed)
+	assert.Equal(t, entry, line)
+
+	assert.NoError(t, s.Remove())
+	assert.Equal(t, "MAILTO=me@example.com\n\n0 * * * * backup\n", fake.crontab)
+}
+
+func TestCronOnlyEntry(t *testing.T) {
+	s, fake := newSchedule(t, Cron)
+	assert.NoError(t, s.Install())
+	assert.NoError(t, s.Remove())
+	assert.Equal(t, "", fake.crontab)
+	assert.Contains(t, fake.commands, "crontab -r")
+
+	fake.commands = nil
+	assert.NoError(t, s.Remove())
+	assert.Equal(t, []string{"crontab -l"}, fake.commands)
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `'/opt/my tools/sao'`, quote("/opt/my tools/sao"))
+	assert.Equal(t, `'it'\''s'`, quote("it's"))
+}
+
+func TestSystemdEscapesPaths(t *testing.T) {
+	s, _ := newSchedule(t, Systemd)
+	s.Exe = `/home/100%$USER/my "tools"/sao`
+	s.LogFile = filepath.Join(t.TempDir(), "100%", "clean.log")
+	assert.NoError(t, s.Install())
+	service, err := os.ReadFile(filepath.Join(s.UnitDir, "devcleaner-clean.service"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(service), `ExecStart="/home/100%%$$USER/my \"tools\"/sao" clean -scheduled`)
+	assert.Contains(t, string(service), "StandardOutput=append:"+strings.ReplaceAll(s.LogFile, "%", "%%")+"\n")
+
+	// append: cannot express whitespace
+	s, fake := newSchedule(t, Systemd)
+	s.LogFile = filepath.Join(t.TempDir(), "my state", "clean.log")
+	assert.ErrorContains(t, s.Install(), "contains whitespace")
+	assert.NoFileExists(t, filepath.Join(s.UnitDir, "devcleaner-clean.service"))
+	assert.Empty(t, fake.commands)
+}
+
+func TestCronEscapesPaths(t *testing.T) {
+	s, fake := newSchedule(t, Cron)
+	s.Exe = "/home/100%/my tools/sao"
+	s.LogFile = filepath.Join(t.TempDir(), "100%", "clean.log")
+	assert.NoError(t, s.Install())
+	escaped := strings.ReplaceAll(s.LogFile, "%", `\%`)
+	assert.Equal(t, `@weekly NO_COLOR=1 nice -n 19 '/home/100\%/my tools/sao' clean -scheduled >> '`+escaped+`' 2>&1 # devcleaner clean`+"\n", fake.crontab)
+
+	s.Exe = "/usr/local/bin/sao\n* * * * * evil"
+	assert.ErrorContains(t, s.Install(), "line break")
+}
@@ -0,0 +1,68 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+var errUnsupported = errors.New("not supported on " + runtime.GOOS)
+
+// Returns the 1 minute load average divided by the number of CPUs, so that 1
+// means every CPU is busy.
+func LoadPerCPU() (float64, error) {
+	load, err := loadAverage()
+	if err != nil {
+		return 0, err
+	}
+	return load / float64(runtime.NumCPU()), nil
+}
+
+// Parses the first load average of /proc/loadavg or of `sysctl -n
+// vm.loadavg`, which is wrapped in braces.
+func parseLoadAverage(s string) (float64, error) {
+	fields := strings.Fields(strings.Trim(strings.TrimSpace(s), "{}"))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("invalid load average %q", s)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// Reports whether the power supplies described in dir, laid out like
+// /sys/class/power_supply, only include discharging batteries. Machines
+// without a battery are never on battery.
+func onBatteryFromSysfs(dir string) (bool, error) {
+	supplies, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	discharging := false
+	for _, supply := range supplies {
+		read := func(name string) string {
+			data, _ := os.ReadFile(filepath.Join(dir, supply.Name(), name))
+			return strings.TrimSpace(string(data))
+		}
+		switch read("type") {
+		case "Mains", "USB":
+			if read("online") == "1" {
+				return false, nil
+			}
+		case "Battery":
+			if read("status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging, nil
+}
+
+// Parses the output of `pmset -g batt`, whose first line tells where the
+// power comes from.
+func parsePmset(s string) bool {
+	first, _, _ := strings.Cut(s, "\n")
+	return strings.Contains(first, "'Battery Power'")
+}
@@ -0,0 +1,20 @@
+package system
+
+import "os/exec"
+
+func loadAverage() (float64, error) {
+	out, err := exec.Command("sysctl", "-n", "vm.loadavg").Output()
+	if err != nil {
+		return 0, err
+	}
+	return parseLoadAverage(string(out))
+}
+
+// Reports whether the machine runs on battery.
+func OnBattery() (bool, error) {
+	out, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return false, err
+	}
+	return parsePmset(string(out)), nil
+}
@@ -0,0 +1,16 @@
+package system
+
+import "os"
+
+func loadAverage() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	return parseLoadAverage(string(data))
+}
+
+// Reports whether the machine runs on battery.
+func OnBattery() (bool, error) {
+	return onBatteryFromSysfs("/sys/class/power_supply")
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package system
+
+func loadAverage() (float64, error) {
+	return 0, errUnsupported
+}
+
+// Reports whether the machine runs on battery.
+func OnBattery() (bool, error) {
+	return false, errUnsupported
+}
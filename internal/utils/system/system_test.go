@@ -0,0 +1,51 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLoadAverage(t *testing.T) {
+	load, err := parseLoadAverage("0.52 0.58 0.59 1/1013 48211\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.52, load)
+	load, err = parseLoadAverage("{ 2.10 1.98 1.87 }\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.10, load)
+	_, err = parseLoadAverage("")
+	assert.Error(t, err)
+}
+
+func writeSupply(t *testing.T, dir string, name string, files map[string]string) {
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0755))
+	for file, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name, file), []byte(content+"\n"), 0644))
+	}
+}
+
+func TestOnBatteryFromSysfs(t *testing.T) {
+	desktop := t.TempDir()
+	onBattery, err := onBatteryFromSysfs(desktop)
+	assert.NoError(t, err)
+	assert.False(t, onBattery)
+
+	laptop := t.TempDir()
+	writeSupply(t, laptop, "AC", map[string]string{"type": "Mains", "online": "0"})
+	writeSupply(t, laptop, "BAT0", map[string]string{"type": "Battery", "status": "Discharging"})
+	onBattery, err = onBatteryFromSysfs(laptop)
+	assert.NoError(t, err)
+	assert.True(t, onBattery)
+
+	writeSupply(t, laptop, "AC", map[string]string{"online": "1"})
+	onBattery, err = onBatteryFromSysfs(laptop)
+	assert.NoError(t, err)
+	assert.False(t, onBattery)
+}
+
+func TestParsePmset(t *testing.T) {
+	assert.True(t, parsePmset("Now drawing from 'Battery Power'\n -InternalBattery-0 (id=1234)\t85%; discharging"))
+	assert.False(t, parsePmset("Now drawing from 'AC Power'\n -InternalBattery-0 (id=1234)\t100%; charged"))
+}
@@ -0,0 +1,75 @@
+package users
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+const passwdPath = "/etc/passwd"
+
+// Regular users have a uid of at least minUid, nobody excluded.
+const minUid = 1000
+const nobodyUid = 65534
+
+type User struct {
+	Name string
+	Uid  int
+	Home string
+}
+
+// Returns the regular users of the machine listed in /etc/passwd, leaving
+// out system accounts and users that cannot log in.
+func List() ([]User, error) {
+	f, err := os.Open(passwdPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list users (%s)", err)
+	}
+	defer f.Close()
+	return parsePasswd(f)
+}
+
+func parsePasswd(r io.Reader) ([]User, error) {
+	var users []User
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil || uid < minUid || uid == nobodyUid {
+			continue
+		}
+		shell := fields[6]
+		if strings.HasSuffix(shell, "/nologin") || strings.HasSuffix(shell, "/false") || fields[5] == "" {
+			continue
+		}
+		users = append(users, User{Name: fields[0], Uid: uid, Home: fields[5]})
+	}
+	return users, scanner.Err()
+}
+
+// Looks up users by name.
+func Lookup(names []string) ([]User, error) {
+	var users []User
+	for _, name := range names {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		// not a number on windows
+		uid, _ := strconv.Atoi(u.Uid)
+		users = append(users, User{Name: u.Username, Uid: uid, Home: u.HomeDir})
+	}
+	return users, nil
+}
@@ -0,0 +1,25 @@
+package users
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePasswd(t *testing.T) {
+	users, err := parsePasswd(strings.NewReader(`root:x:0:0:root:/root:/bin/bash
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+# a comment
+nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin
+alice:x:1000:1000:Alice,,,:/home/alice:/bin/bash
+ci:x:1001:1001::/var/lib/ci:/bin/sh
+locked:x:1002:1002::/home/locked:/bin/false
+broken line
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []User{
+		{Name: "alice", Uid: 1000, Home: "/home/alice"},
+		{Name: "ci", Uid: 1001, Home: "/var/lib/ci"},
+	}, users)
+}
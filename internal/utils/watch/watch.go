@@ -0,0 +1,146 @@
+package watch
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/disk"
+)
+
+// A cache that can be deleted to free space.
+type Candidate struct {
+	Path string
+	Size int64
+	App  string
+	// ID of the device on which deleting the cache frees space, see
+	// disk.LinkDevice
+	Device uint64
+}
+
+// Watches the free space of filesystems and deletes caches on those running
+// low, until enough space is free.
+type Watcher struct {
+	// A path of each filesystem to watch
+	Mounts []string
+	// Caches are deleted when the free space falls below MinFree, until it
+	// reaches TargetFree
+	MinFree    disk.Threshold
+	TargetFree disk.Threshold
+	// Minimum time between two cleans of a filesystem
+	Cooldown time.Duration
+	// Returns the caches that may be deleted, the first ones being deleted
+	// first
+	Candidates func() ([]Candidate, error)
+	Remove     func(Candidate) error
+	// Structured log of what the watcher does
+	Events *slog.Logger
+
+	usage     func(string) (disk.Usage, error)
+	device    func(string) (uint64, error)
+	now       func() time.Time
+	lastClean map[string]time.Time
+}
+
+// Checks the filesystems every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) error {
+	w.Events.Info("started", "mounts", w.Mounts, "min_free", w.MinFree.String(), "target_free", w.TargetFree.String(), "interval", interval.String(), "cooldown", w.Cooldown.String())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.Check()
+		select {
+		case <-ctx.Done():
+			w.Events.Info("stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Checks each filesystem once and cleans those running low.
+func (w *Watcher) Check() {
+	if w.usage == nil {
+		w.usage = disk.GetUsage
+	}
+	if w.device == nil {
+		w.device = disk.Device
+	}
+	if w.now == nil {
+		w.now = time.Now
+	}
+	if w.lastClean == nil {
+		w.lastClean = map[string]time.Time{}
+	}
+
+	// computed once, for the first filesystem running low
+	var candidates []Candidate
+	scanned := false
+	for _, mount := range w.Mounts {
+		usage, err := w.usage(mount)
+		if err != nil {
+			w.Events.Error("error", "mount", mount, "error", err.Error())
+			continue
+		}
+		minFree := w.MinFree.Of(usage)
+		if usage.Free >= minFree {
+			w.Events.Debug("checked", "mount", mount, "free", usage.Free)
+			continue
+		}
+		if last, ok := w.lastClean[mount]; ok && w.now().Sub(last) < w.Cooldown {
+			w.Events.Info("cooldown", "mount", mount, "free", usage.Free, "min_free", minFree, "until", last.Add(w.Cooldown).Format(time.RFC3339))
+			continue
+		}
+		w.Events.Warn("low_space", "mount", mount, "free", usage.Free, "min_free", minFree)
+		w.lastClean[mount] = w.now()
+
+		if !scanned {
+			scanned = true
+			candidates, err = w.Candidates()
+			if err != nil {
+				w.Events.Error("error", "mount", mount, "error", err.Error())
+				continue
+			}
+		}
+		candidates = w.clean(mount, usage, candidates)
+	}
+}
+
+// Deletes the candidates on the filesystem of mount until the target is
+// reached, and returns the candidates left.
+func (w *Watcher) clean(mount string, usage disk.Usage, candidates []Candidate) []Candidate {
+	device, err := w.device(mount)
+	if err != nil {
+		w.Events.Error("error", "mount", mount, "error", err.Error())
+		return candidates
+	}
+	target := max(w.TargetFree.Of(usage), w.MinFree.Of(usage))
+	var left []Candidate
+	var freed int64
+	for i, c := range candidates {
+		if usage.Free >= target {
+			left = append(left, c)
+			continue
+		}
+		if c.Device != device {
+			left = append(left, c)
+			continue
+		}
+		if err := w.Remove(c); err != nil {
+			w.Events.Error("delete_failed", "mount", mount, "path", c.Path, "app", c.App, "error", err.Error())
+			continue
+		}
+		freed += c.Size
+		w.Events.Info("deleted", "mount", mount, "path", c.Path, "app", c.App, "size", c.Size)
+		if usage, err = w.usage(mount); err != nil {
+			w.Events.Error("error", "mount", mount, "error", err.Error())
+			return append(left, candidates[i+1:]...)
+		}
+	}
+	if usage.Free >= target {
+		w.Events.Info("target_reached", "mount", mount, "free", usage.Free, "target_free", target, "freed", freed)
+	} else {
+		w.Events.Warn("target_not_reached", "mount", mount, "free", usage.Free, "target_free", target, "freed", freed)
+	}
+	return left
+}
@@ -0,0 +1,121 @@
+package watch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/disk"
+	"github.com/stretchr/testify/assert"
+)
+
+// Two filesystems of 100 bytes, / and /home, and caches on both.
+type fakeDisks struct {
+	free    map[string]int64
+	removed []string
+	now     time.Time
+}
+
+func (f *fakeDisks) mountOf(p string) string {
+	if strings.HasPrefix(p, "/home") {
+		return "/home"
+	}
+	return "/"
+}
+
+func (f *fakeDisks) watcher(events *bytes.Buffer, candidates ...Candidate) *Watcher {
+	return &Watcher{
+		Mounts:     []string{"/", "/home"},
+		MinFree:    disk.Threshold{Percent: 10},
+		TargetFree: disk.Threshold{Bytes: 30},
+		Cooldown:   time.Hour,
+		Candidates: func() ([]Candidate, error) {
+			// deleted caches are not found by later scans
+			var left []Candidate
+			for _, c := range candidates {
+				if !slices.Contains(f.removed, c.Path) {
+					left = append(left, c)
+				}
+			}
+			return left, nil
+		},
+		Remove: func(c Candidate) error {
+			if c.Path == "/home/broken" {
+				return errors.New("permission denied")
+			}
+			f.removed = append(f.removed, c.Path)
+			f.free[f.mountOf(c.Path)] += c.Size
+			return nil
+		},
+		Events: slog.New(slog.NewJSONHandler(events, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		usage: func(mount string) (disk.Usage, error) {
+			free, ok := f.free[mount]
+			if !ok {
+				return disk.Usage{}, os.ErrNotExist
+			}
+			return disk.Usage{Total: 100, Free: free}, nil
+		},
+		device: func(p string) (uint64, error) {
+			if strings.HasPrefix(p, "/home") {
+				return 2, nil
+			}
+			return 1, nil
+		},
+		now: func() time.Time { return f.now },
+	}
+}
+
+// Returns the msg of each event.
+func eventNames(t *testing.T, events *bytes.Buffer) []string {
+	var names []string
+	decoder := json.NewDecoder(events)
+	for decoder.More() {
+		var event map[string]any
+		assert.NoError(t, decoder.Decode(&event))
+		names = append(names, event["msg"].(string))
+	}
+	return names
+}
+
+func TestCheck(t *testing.T) {
+	f := &fakeDisks{free: map[string]int64{"/": 50, "/home": 5}, now: time.Now()}
+	var events bytes.Buffer
+	w := f.watcher(&events,
+		Candidate{Path: "/var/cache/apt", Size: 40, Device: 1},
+		Candidate{Path: "/home/broken", Size: 50, Device: 2},
+		Candidate{Path: "/home/me/.cache/go-build", Size: 15, Device: 2},
+		Candidate{Path: "/home/me/.npm", Size: 10, Device: 2},
+		Candidate{Path: "/home/me/.gradle", Size: 20, Device: 2},
+	)
+	w.Check()
+	// only caches on /home, in order, until 30 bytes are free
+	assert.Equal(t, []string{"/home/me/.cache/go-build", "/home/me/.npm"}, f.removed)
+	assert.Equal(t, int64(30), f.free["/home"])
+	assert.Equal(t, []string{"checked", "low_space", "delete_failed", "deleted", "deleted", "target_reached"}, eventNames(t, &events))
+
+	// the cooldown prevents cleaning again
+	f.free["/home"] = 5
+	w.Check()
+	assert.Equal(t, []string{"checked", "cooldown"}, eventNames(t, &events))
+	f.now = f.now.Add(2 * time.Hour)
+	f.free["/home"] = 0
+	w.Check()
+	assert.Equal(t, []string{"/home/me/.cache/go-build", "/home/me/.npm", "/home/me/.gradle"}, f.removed)
+	assert.Equal(t, []string{"checked", "low_space", "delete_failed", "deleted", "target_not_reached"}, eventNames(t, &events))
+}
+
+func TestCheckErrors(t *testing.T) {
+	f := &fakeDisks{free: map[string]int64{"/": 5}, now: time.Now()}
+	var events bytes.Buffer
+	w := f.watcher(&events)
+	w.Candidates = func() ([]Candidate, error) { return nil, errors.New("manifest unavailable") }
+	w.Check()
+	assert.Equal(t, []string{"low_space", "error", "error"}, eventNames(t, &events))
+	assert.Empty(t, f.removed)
+}
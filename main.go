@@ -1,55 +1,98 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
-	"time"
+	"strings"
 
-	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
-	"github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
 	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
-	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
 )
 
-func main() {
-	l := log.NewFromEnv()
+type command struct {
+	name  string
+	usage string
+	run   func(l *log.Logger, args []string) error
+}
+
+var commands = []command{
+	{name: "scan", usage: "Report the disk usage of the caches of installed tools (default)", run: runScan},
+	{name: "clean", usage: "Delete the caches of installed tools", run: runClean},
+	{name: "manifest", usage: "Work with manifest files", run: runManifest},
+	{name: "history", usage: "Show how the disk usage of caches evolves", run: runHistory},
+	{name: "audit", usage: "List the deletions of caches", run: runAudit},
+	{name: "explain", usage: "Show how a path pattern is resolved", run: runExplain},
+	{name: "config", usage: "Show the effective configuration", run: runConfig},
+	{name: "schedule", usage: "Clean caches automatically every week", run: runSchedule},
+	{name: "watch", usage: "Clean caches when a filesystem runs low on free space", run: runWatch},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [arguments]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.usage)
+	}
+}
+
+// Applies the flags that were set on the command line to the config, keys
+// maps flag names to config settings.
+func applyFlags(flags *flag.FlagSet, keys map[string]string) error {
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		if key, ok := keys[f.Name]; ok && err == nil {
+			err = config.Runtime.SetFlag(key, f.Value.String())
+		}
+	})
+	return err
+}
+
+func flagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
 
-	l.Debug("Getting manifest...")
-	timer := time.Now()
-	manifest, err := apps.GetManifest(l)
-	took := time.Since(timer)
+// Reports each error of a possibly joined error.
+func reportErrors(l *log.Logger, err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			reportErrors(l, err)
+		}
+		return
+	}
+	l.Error("%s", err)
+}
+
+func main() {
+	cfg, err := config.Load()
 	if err != nil {
-		l.Error("Error fetching manifest: %s", err)
+		reportErrors(log.New(), err)
 		os.Exit(1)
 	}
-	l.Debug("Manifest fetched (took %s)", took)
-	ctx := path.NewPathContext()
-	var total int64
-	for _, app := range manifest.Apps {
-		l.Debug("  Evaluating app %s", app.Name)
-		path, err := app.Path.Eval(ctx)
-		if err != nil {
-			l.Debug("  Skipping app %s: %s", app.Name, err)
-			continue
-		}
-		l.Info("  Found %s at %s", app.Name, path)
-		for _, cache := range app.Caches {
-			l.Debug("    Evaluating cache %s", cache)
-			path, err := cache.Eval(ctx)
-			if err != nil {
-				l.Debug("    Skipping cache %s: %s", cache, err)
-				continue
-			}
-			l.Info("    Found cache path %s", path)
-			size, err := io.DiskUsage(path)
-			total += size
-			if err != nil {
-				l.Error("Error calculating disk usage: %s", err)
+	config.Runtime = *cfg
+	l := log.NewFromEnv()
+
+	name, args := "scan", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage()
+		return
+	}
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(l, args); err != nil {
+				l.Error("%s", err)
 				os.Exit(1)
 			}
-			l.Debug("    Cache %s takes %d bytes", path, size)
-			l.Info("    Cache %s takes %s", path, io.HumanizeBytes(size))
+			return
 		}
 	}
-
-	l.Info("Total disk usage: %s", io.HumanizeBytes(total))
+	l.Error("Unknown command %s", name)
+	usage()
+	os.Exit(2)
 }
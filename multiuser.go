@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/users"
+)
+
+// Flags selecting the users whose caches are scanned instead of the
+// current user's.
+type userFlags struct {
+	all   *bool
+	names *string
+}
+
+func addUserFlags(flags *flag.FlagSet) userFlags {
+	return userFlags{
+		all:   flags.Bool("all-users", false, "scan every regular user listed in /etc/passwd, usually as root"),
+		names: flags.String("users", "", "comma separated users to scan, usually as root"),
+	}
+}
+
+func (f userFlags) enabled() bool {
+	return *f.all || *f.names != ""
+}
+
+func (f userFlags) users(l *log.Logger) ([]users.User, error) {
+	if *f.all && *f.names != "" {
+		return nil, errors.New("-all-users and -users cannot be used together")
+	}
+	if os.Geteuid() != 0 && runtime.GOOS != "windows" {
+		return nil, errors.New("reading the caches of other users needs root, run again with sudo")
+	}
+	if *f.all {
+		return users.List()
+	}
+	var names []string
+	for _, name := range strings.Split(*f.names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return users.Lookup(names)
+}
+
+// Context evaluating patterns for u, with their HOME and XDG defaults.
+// Commands are not run, they would run as the current user.
+func userPathContext(u users.User) *path.PathContext {
+	return path.NewSyntheticPathContext(u.Home, runtime.GOOS, runtime.GOARCH, map[string]string{
+		"HOME": u.Home,
+		"USER": u.Name,
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/config"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/disk"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/io"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/safety"
+)
+
+type appResult struct {
+	app    *apps.App
+	path   string
+	caches []cacheResult
+	// Exclusion rule of the config matching the app, its caches are not
+	// evaluated
+	excluded string
+	// Number of caches that could not be located because they need commands,
+	// which are disabled, e.g. for other users
+	unlocated int
+}
+
+type cacheResult struct {
+	cache apps.Cache
+	path  string
+	size  int64
+	// The file as scanned, checked again before deleting it
+	info os.FileInfo
+	// ID of the device of the filesystem the cache is on
+	device uint64
+	// Exclusion rule of the config matching the path, its size is not
+	// computed
+	excluded string
+	// Why the cache could not be read, its size is unknown
+	err error
+}
+
+func (r *appResult) size() int64 {
+	var total int64
+	for _, c := range r.caches {
+		if c.excluded != "" || c.err != nil {
+			continue
+		}
+		total += c.size
+	}
+	return total
+}
+
+func newPathContext() *path.PathContext {
+	ctx := path.NewPathContext()
+	ctx.SetSafeMode(config.Runtime.SafeMode)
+	ctx.SetCommandTimeout(config.Runtime.CommandTimeout)
+	return ctx
+}
+
+// Evaluates the apps of the manifest and the disk usage of their caches.
+// Apps that are not installed and caches that do not exist are skipped,
+// excluded ones are kept with the rule excluding them and unreadable ones
+// with the error. Caches that need commands when they are disabled are
+// counted as unlocated.
+// With a root, the system mounted there is scanned instead of this one.
+func scanManifest(l *log.Logger, manifest *apps.Manifest, ctx *path.PathContext, root string) []appResult {
+	var results []appResult
+	opts := []path.Option{path.WithLogger(l.Slog()), path.WithRoot(root)}
+	for i := range manifest.Apps {
+		app := &manifest.Apps[i]
+		l.Debug("  Evaluating app %s", app.Name)
+		ctx := ctx
+		if !app.Local() {
+			// anyone controlling the manifest URL could run anything
+			ctx = ctx.RestrictCommands(config.Runtime.AllowedCommands)
+		}
+		appPath, err := app.Path.Eval(ctx, opts...)
+		if errors.Is(err, path.ErrSafeMode) {
+			l.Warn("  Could not locate %s, it needs commands, which are disabled", app.Name)
+			results = append(results, appResult{app: app, unlocated: len(app.Caches)})
+			continue
+		} else if err != nil {
+			l.Debug("  Skipping app %s: %s", app.Name, err)
+			continue
+		}
+		if rule := excludedApp(app); rule != "" {
+			l.Info("  Found %s at %s, excluded by %s", app.Name, appPath, rule)
+			results = append(results, appResult{app: app, path: appPath, excluded: rule})
+			continue
+		}
+		l.Info("  Found %s at %s%s", app.Name, appPath, describeApp(app))
+		version, err := app.DetectVersion(ctx)
+		versionDisabled := errors.Is(err, path.ErrSafeMode)
+		if err != nil {
+			l.Debug("  Could not detect the version of %s: %s", app.Name, err)
+		} else if version != "" {
+			l.Debug("  %s version is %s", app.Name, version)
+		}
+		// {app_path} is a path of the scanned system
+		appCtx := ctx.ForApp(unrooted(appPath, root), version)
+		result := appResult{app: app, path: appPath}
+		for _, cache := range app.Caches {
+			l.Debug("    Evaluating cache %s", cache)
+			cachePaths, err := cache.Path.EvalAll(appCtx, opts...)
+			if errors.Is(err, path.ErrSafeMode) || versionDisabled && errors.Is(err, path.ErrUnset) {
+				l.Warn("    Could not locate cache %s, it needs commands, which are disabled", cache)
+				result.unlocated++
+				continue
+			} else if err != nil {
+				l.Debug("    Skipping cache %s: %s", cache, err)
+				continue
+			}
+			for _, cachePath := range cachePaths {
+				if rule := excludedPath(ctx, unrooted(cachePath, root)); rule != "" {
+					l.Info("    Cache %s is excluded by %s", cachePath, rule)
+					result.caches = append(result.caches, cacheResult{cache: cache, path: cachePath, excluded: rule})
+					continue
+				}
+				l.Info("    Found cache path %s", cachePath)
+				info, device, size, err := readCache(cachePath)
+				if err != nil {
+					l.Warn("    Could not read cache %s: %s", cachePath, err)
+					result.caches = append(result.caches, cacheResult{cache: cache, path: cachePath, err: err})
+					continue
+				}
+				l.Debug("    Cache %s takes %d bytes", cachePath, size)
+				l.Info("    Cache %s takes %s (%s)", cachePath, io.HumanizeBytes(size), app.CacheRisk(cache))
+				if cache.Description != "" {
+					l.Info("      %s", cache.Description)
+				}
+				result.caches = append(result.caches, cacheResult{cache: cache, path: cachePath, size: size, info: info, device: device})
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Returns the number of caches of the results that could not be located.
+func unlocatedCaches(results []appResult) int {
+	n := 0
+	for _, r := range results {
+		n += r.unlocated
+	}
+	return n
+}
+
+// Returns what is needed to clean a cache: the file as scanned, the device
+// of its filesystem and its disk usage.
+func readCache(cachePath string) (os.FileInfo, uint64, int64, error) {
+	info, err := safety.Snapshot(cachePath)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	// a linked cache is deleted as a link, which frees nothing on the
+	// filesystem it points to
+	device, err := disk.LinkDevice(cachePath)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	size, err := io.DiskUsage(cachePath)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return info, device, size, nil
+}
+
+// Keeps the caches on the filesystem of the given device.
+func onDevice(l *log.Logger, results []appResult, device uint64) []appResult {
+	var kept []appResult
+	for _, r := range results {
+		caches := r.caches
+		r.caches = nil
+		for _, c := range caches {
+			if c.excluded == "" && c.device != device {
+				l.Debug("Skipping %s, it is on another filesystem", c.path)
+				continue
+			}
+			r.caches = append(r.caches, c)
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+func unrooted(p string, root string) string {
+	if root == "" {
+		return p
+	}
+	return string(filepath.Separator) + strings.TrimLeft(strings.TrimPrefix(p, filepath.Clean(root)), `/\`)
+}
+
+func describeApp(app *apps.App) string {
+	switch {
+	case app.Description != "" && app.Category != "":
+		return fmt.Sprintf(" (%s: %s)", app.Category, app.Description)
+	case app.Description != "":
+		return fmt.Sprintf(" (%s)", app.Description)
+	case app.Category != "":
+		return fmt.Sprintf(" (%s)", app.Category)
+	default:
+		return ""
+	}
+}
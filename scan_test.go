@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/apps"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/disk"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/log"
+	"github.com/gaetschwartz/devcleaner-go/internal/utils/path"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnDevice(t *testing.T) {
+	l := log.New()
+	l.CurrentLevel = log.LevelNone
+	results := []appResult{
+		{app: &apps.App{Name: "Go"}, caches: []cacheResult{
+			{path: "/home/me/.cache/go-build", device: 2},
+			{path: "/home/me/go/pkg/mod", device: 3},
+		}},
+		{app: &apps.App{Name: "Docker"}, caches: []cacheResult{
+			{path: "/var/lib/docker", device: 1},
+			{path: "/var/cache/docker", device: 1, excluded: "exclude.paths=/var/cache/*"},
+		}},
+		{app: &apps.App{Name: "Xcode"}, excluded: "exclude.apps=Xcode"},
+	}
+
+	var kept []string
+	for _, r := range onDevice(l, results, 2) {
+		for _, c := range r.caches {
+			kept = append(kept, c.path)
+		}
+	}
+	// excluded caches stay listed, whatever their filesystem
+	assert.Equal(t, []string{"/home/me/.cache/go-build", "/var/cache/docker"}, kept)
+	assert.Len(t, onDevice(l, results, 4), 3)
+}
+
+func TestMountOfLinkedCache(t *testing.T) {
+	dir := t.TempDir()
+	dirDevice, err := disk.Device(dir)
+	if err != nil {
+		t.Skip("devices are not supported")
+	}
+	// a filesystem other than the one of dir
+	target := "/proc/self"
+	if d, err := disk.Device(target); err != nil || d == dirDevice {
+		t.Skip("no other filesystem")
+	}
+	link := filepath.Join(dir, "cache")
+	assert.NoError(t, os.Symlink(target, link))
+	device, err := disk.LinkDevice(link)
+	assert.NoError(t, err)
+
+	var r scanReport
+	mount := r.mount(cacheResult{path: link, device: device, size: 10})
+	if assert.NotNil(t, mount) {
+		dirMount, _ := disk.MountPoint(dir)
+		assert.Equal(t, dirMount, mount.Path)
+	}
+	// later caches of the same filesystem are in the same group
+	assert.Same(t, &r.Mounts[0], r.mount(cacheResult{path: dir, device: dirDevice}))
+}
+
+func TestScanCountsCachesNeedingCommands(t *testing.T) {
+	l := log.New()
+	l.CurrentLevel = log.LevelNone
+	home := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".cache", "found"), 0755))
+	manifest := &apps.Manifest{Apps: []apps.App{
+		{Name: "Go", Path: "{home}", VersionCommand: "go version", Caches: []apps.Cache{
+			{Path: "{cmd:go env GOCACHE}"},
+			// the version needs a command too
+			{Path: "{home}/.cache/go{version}"},
+			{Path: "{home}/.cache/found"},
+			{Path: "{home}/.cache/missing"},
+		}},
+		{Name: "Tool", Path: "{cmd:which tool}", Caches: []apps.Cache{{Path: "{home}/.tool"}, {Path: "{home}/.tool2"}}},
+	}}
+	// like the context of another user
+	ctx := path.NewSyntheticPathContext(home, "", "", nil)
+
+	results := scanManifest(l, manifest, ctx, "")
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, 2, results[0].unlocated)
+		assert.Len(t, results[0].caches, 1)
+		assert.Equal(t, 2, results[1].unlocated)
+	}
+	assert.Equal(t, 4, unlocatedCaches(results))
+}